@@ -0,0 +1,60 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// markAbandoned tags the consumer events of a group's topic whose committed offsets have not moved for
+// abandoned_after while the group's offsets moved on some other topic, as when a group is reconfigured to
+// drop a topic and its last commit lingers with a growing lag
+func (bt *Kafkabeat) markAbandoned(events []common.MapStr) {
+	if bt.abandonedAfter <= 0 {
+		return
+	}
+	bt.trackAbandoned(events, time.Now())
+}
+
+// trackAbandoned tags events with abandoned, leaving out their alert fields when suppress_abandoned_alerts
+// is set. A group's commits on a topic are taken to move when their sum changes, and a topic seen for the
+// first time counts as moving, so that a restarted beat waits out abandoned_after before tagging it
+func (bt *Kafkabeat) trackAbandoned(events []common.MapStr, now time.Time) {
+	sums := make(map[string]int64)
+	groups := make(map[string]interface{})
+	for _, event := range events {
+		key := stateKey("topicCommits", event["group"], event["topic"])
+		sums[key] += event["offset"].(int64)
+		groups[key] = event["group"]
+	}
+	moved := make(map[string]time.Time)
+	for key, sum := range sums {
+		previous, ok := bt.state.Get(key)
+		if ok && previous.(*offsetSeen).offset == sum {
+			// keep the entry recent so that eviction does not restart the wait
+			bt.state.Put(key, previous)
+			moved[key] = previous.(*offsetSeen).since
+			continue
+		}
+		bt.state.Put(key, &offsetSeen{sum, now})
+		bt.state.Put(stateKey("groupCommits", groups[key]), now)
+		moved[key] = now
+	}
+	for _, event := range events {
+		key := stateKey("topicCommits", event["group"], event["topic"])
+		if now.Sub(moved[key]) <= bt.abandonedAfter {
+			continue
+		}
+		groupMoved, ok := bt.state.Get(stateKey("groupCommits", event["group"]))
+		if !ok || now.Sub(groupMoved.(time.Time)) > bt.abandonedAfter {
+			// the group as a whole has stopped, which is left for stale to tell
+			continue
+		}
+		event["abandoned"] = true
+		if bt.suppressAbandoned {
+			delete(event, "overThreshold")
+			delete(event, "lagThreshold")
+			delete(event, "overBudget")
+		}
+	}
+}
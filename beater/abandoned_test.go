@@ -0,0 +1,51 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestAbandonedTopic(t *testing.T) {
+	bt := New()
+	bt.lagThreshold = 100
+	bt.abandonedAfter = 10 * time.Minute
+	bt.suppressAbandoned = true
+	start := time.Now()
+	// billing keeps committing on orders while its offset on the dropped refunds topic sits still
+	tick := func(ordersOffset int64, after time.Duration) (common.MapStr, common.MapStr) {
+		orders := common.MapStr{"type": "consumer", "group": "billing", "topic": "orders", "partition": int32(0), "offset": ordersOffset, "lag": int64(10)}
+		refunds := common.MapStr{"type": "consumer", "group": "billing", "topic": "refunds", "partition": int32(0), "offset": int64(700), "lag": int64(5000)}
+		for _, events := range [][]common.MapStr{{orders}, {refunds}} {
+			bt.markLagThresholds(events)
+			bt.trackAbandoned(events, start.Add(after))
+		}
+		return orders, refunds
+	}
+
+	_, refunds := tick(100, 0)
+	if _, ok := refunds["abandoned"]; ok {
+		t.Errorf("expected a topic seen for the first time not to be abandoned, got %v", refunds)
+	}
+	_, refunds = tick(200, 5*time.Minute)
+	if _, ok := refunds["abandoned"]; ok {
+		t.Errorf("expected no tag within abandoned_after, got %v", refunds)
+	}
+	orders, refunds := tick(300, 11*time.Minute)
+	if refunds["abandoned"] != true {
+		t.Errorf("expected the stuck topic of an active group to be abandoned, got %v", refunds)
+	}
+	if _, ok := refunds["overThreshold"]; ok {
+		t.Errorf("expected the alert fields of an abandoned topic to be left out, got %v", refunds)
+	}
+	if _, ok := orders["abandoned"]; ok {
+		t.Errorf("expected the topic the group commits on not to be abandoned, got %v", orders)
+	}
+
+	// once the group stops committing altogether it is stale rather than abandoned
+	_, refunds = tick(300, 30*time.Minute)
+	if _, ok := refunds["abandoned"]; ok {
+		t.Errorf("expected a group with no commits anywhere not to be abandoned, got %v", refunds)
+	}
+}
@@ -0,0 +1,116 @@
+package beater
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// describeTopicAcls returns the ACLs allowing access to topics, asking the first broker that answers.
+// false when the cluster has no authorizer enabled or none answered
+func describeTopicAcls() ([]*sarama.ResourceAcls, bool) {
+	request := sarama.DescribeAclsRequest{AclFilter: sarama.AclFilter{
+		ResourceType:   sarama.AclResourceTopic,
+		Operation:      sarama.AclOperationAny,
+		PermissionType: sarama.AclPermissionAllow,
+	}}
+	if client.Config().Version.IsAtLeast(sarama.V2_0_0_0) {
+		// v1 is the first version to describe prefixed ACLs
+		request.Version = 1
+		request.ResourcePatternTypeFilter = sarama.AclPatternAny
+	}
+	for _, broker := range client.Brokers() {
+		err := broker.Open(client.Config())
+		if err != nil && err != sarama.ErrAlreadyConnected {
+			logp.Err("Unable to connect to broker %v to describe ACLs: %v", broker.ID(), err)
+			continue
+		}
+		res, err := broker.DescribeAcls(&request)
+		if err == nil && res.Err != sarama.ErrNoError {
+			err = res.Err
+		}
+		if err == sarama.ErrSecurityDisabled {
+			logp.Debug("kafkabeat", "ACLs aren't enabled on the cluster, publishing no topic ACLs")
+			return nil, false
+		}
+		if err != nil {
+			logp.Err("Unable to describe ACLs through broker %v: %v", broker.ID(), err)
+			collectionErrors.record("describeAcls", common.MapStr{}, err)
+			continue
+		}
+		return res.ResourceAcls, true
+	}
+	return nil, false
+}
+
+// aclMatches is whether an ACL's resource covers the topic, by name, prefix or the * wildcard
+func aclMatches(resource sarama.Resource, topic string) bool {
+	if resource.ResourcePatternType == sarama.AclPatternPrefixed {
+		return strings.HasPrefix(topic, resource.ResourceName)
+	}
+	return resource.ResourceName == topic || resource.ResourceName == "*"
+}
+
+// topicAclEvents builds an event per topic listing the principals allowed to write to it as producers and
+// to read from it as consumers
+func topicAclEvents(topics []string, resources []*sarama.ResourceAcls) []common.MapStr {
+	events := make([]common.MapStr, 0, len(topics))
+	for _, topic := range topics {
+		producers := make(map[string]bool)
+		consumers := make(map[string]bool)
+		for _, resource := range resources {
+			if !aclMatches(resource.Resource, topic) {
+				continue
+			}
+			for _, acl := range resource.Acls {
+				if acl.PermissionType != sarama.AclPermissionAllow {
+					continue
+				}
+				switch acl.Operation {
+				case sarama.AclOperationAll:
+					producers[acl.Principal] = true
+					consumers[acl.Principal] = true
+				case sarama.AclOperationWrite:
+					producers[acl.Principal] = true
+				case sarama.AclOperationRead:
+					consumers[acl.Principal] = true
+				}
+			}
+		}
+		events = append(events, common.MapStr{
+			"@timestamp": common.Time(time.Now()),
+			"type":       "topic_acl",
+			"topic":      topic,
+			"producers":  principals(producers),
+			"consumers":  principals(consumers),
+		})
+	}
+	return events
+}
+
+// principals lists the principals sorted
+func principals(found map[string]bool) []string {
+	sorted := make([]string, 0, len(found))
+	for principal := range found {
+		sorted = append(sorted, principal)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// publishTopicAcls publishes the principals with access to each monitored topic when topic_acls is set
+func (bt *Kafkabeat) publishTopicAcls(b *beat.Beat) {
+	if !bt.topicAcls {
+		return
+	}
+	resources, ok := describeTopicAcls()
+	if !ok {
+		return
+	}
+	bt.publish(b, topicAclEvents(bt.topics, resources))
+}
@@ -0,0 +1,58 @@
+package beater
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestTopicAcls(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1, "orders-eu": 1})
+	defer broker.Close()
+	allow := func(principal string, operation sarama.AclOperation) *sarama.Acl {
+		return &sarama.Acl{Principal: principal, Host: "*", Operation: operation, PermissionType: sarama.AclPermissionAllow}
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"DescribeAclsRequest": sarama.NewMockWrapper(&sarama.DescribeAclsResponse{
+			Version: 1,
+			ResourceAcls: []*sarama.ResourceAcls{
+				{
+					Resource: sarama.Resource{ResourceType: sarama.AclResourceTopic, ResourceName: "orders", ResourcePatternType: sarama.AclPatternLiteral},
+					Acls:     []*sarama.Acl{allow("User:checkout", sarama.AclOperationWrite), allow("User:billing", sarama.AclOperationRead)},
+				},
+				{
+					Resource: sarama.Resource{ResourceType: sarama.AclResourceTopic, ResourceName: "orders-", ResourcePatternType: sarama.AclPatternPrefixed},
+					Acls:     []*sarama.Acl{allow("User:replicator", sarama.AclOperationAll)},
+				},
+			},
+		}),
+	})
+	conf := sarama.NewConfig()
+	conf.Version = sarama.V2_0_0_0
+	conf.ApiVersionsRequest = false
+	connectTestClient(t, broker, conf)
+	defer client.Close()
+
+	bt := New()
+	bt.topics = []string{"orders", "orders-eu"}
+	bt.topicAcls = true
+	published := &capturePublisher{}
+	bt.publishTopicAcls(&beat.Beat{Events: published})
+	events := published.ofType("topic_acl")
+	if len(events) != 2 {
+		t.Fatalf("expected an event per topic, got %v", events)
+	}
+	expected := map[string][2][]string{
+		"orders":    {{"User:checkout"}, {"User:billing"}},
+		"orders-eu": {{"User:replicator"}, {"User:replicator"}},
+	}
+	for _, event := range events {
+		principals := expected[event["topic"].(string)]
+		if !reflect.DeepEqual(event["producers"], principals[0]) || !reflect.DeepEqual(event["consumers"], principals[1]) {
+			t.Errorf("expected producers %v and consumers %v, got %v", principals[0], principals[1], event)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package beater
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// activeGroups keeps the groups the brokers coordinate which have members, describing the groups of each
+// coordinator at once. Empty and Dead groups, e.g. those left behind by consumers long gone, are dropped.
+// Groups whose coordinator can't be found or which can't be described are of unknown state, and are kept
+func activeGroups(groups []string) []string {
+	coordinators := make(map[*sarama.Broker][]string)
+	var active []string
+	for _, group := range groups {
+		broker, err := coordinatorFor(group)
+		if err != nil {
+			logp.Debug("kafkabeat", "Unable to identify group coordinator for group %v: %v", group, err)
+			collectionErrors.record("coordinator", common.MapStr{"group": group}, err)
+			active = append(active, group)
+			continue
+		}
+		coordinators[broker] = append(coordinators[broker], group)
+	}
+	for broker, coordinated := range coordinators {
+		res, err := broker.DescribeGroups(&sarama.DescribeGroupsRequest{Groups: coordinated})
+		if err != nil {
+			logp.Debug("kafkabeat", "Unable to describe groups %v: %v", coordinated, err)
+			for _, group := range coordinated {
+				collectionErrors.record("describeGroups", common.MapStr{"group": group}, err)
+				invalidateCoordinator(group)
+			}
+			active = append(active, coordinated...)
+			continue
+		}
+		brokerThrottles.record(res.ThrottleTimeMs)
+		for _, description := range res.Groups {
+			if description.Err != sarama.ErrNoError {
+				logp.Debug("kafkabeat", "Unable to describe group %s: %v", description.GroupId, description.Err)
+				collectionErrors.record("describeGroups", common.MapStr{"group": description.GroupId}, description.Err)
+				if movedCoordinator(description.Err) {
+					invalidateCoordinator(description.GroupId)
+				}
+				active = append(active, description.GroupId)
+				continue
+			}
+			if description.State == "Empty" || description.State == "Dead" || len(description.Members) == 0 {
+				continue
+			}
+			active = append(active, description.GroupId)
+		}
+	}
+	return active
+}
@@ -0,0 +1,87 @@
+package beater
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestActiveGroupsOnly(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	member := map[string]*sarama.GroupMemberDescription{"consumer-1": {ClientId: "consumer-1"}}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"ListGroupsRequest": sarama.NewMockListGroupsResponse(t).
+			AddGroup("billing", "consumer").
+			AddGroup("abandoned", "consumer").
+			AddGroup("draining", "consumer"),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "abandoned", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "draining", broker),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t).
+			AddGroupDescription("billing", &sarama.GroupDescription{GroupId: "billing", State: "Stable", ProtocolType: "consumer", Members: member}).
+			AddGroupDescription("abandoned", &sarama.GroupDescription{GroupId: "abandoned", State: "Empty", ProtocolType: "consumer"}).
+			AddGroupDescription("draining", &sarama.GroupDescription{GroupId: "draining", State: "PreparingRebalance", ProtocolType: "consumer"}),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	restore := zookeeperGroups
+	zookeeperGroups = func() ([]string, error) { return []string{"legacy"}, nil }
+	defer func() { zookeeperGroups = restore }()
+
+	bt := New()
+	bt.refreshGroups()
+	if expected := []string{"abandoned", "billing", "draining", "legacy"}; !reflect.DeepEqual(bt.groups, expected) {
+		t.Errorf("expected every group to be monitored when disabled, got %v", bt.groups)
+	}
+
+	// groups only in Zookeeper aren't coordinated by the brokers so can't be told apart, and are kept
+	bt.activeGroupsOnly = true
+	bt.refreshGroups()
+	if expected := []string{"billing", "legacy"}; !reflect.DeepEqual(bt.groups, expected) {
+		t.Errorf("expected only the active groups to be monitored, got %v", bt.groups)
+	}
+}
+
+func TestActiveGroupsFailures(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	member := map[string]*sarama.GroupMemberDescription{"consumer-1": {ClientId: "consumer-1"}}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "abandoned", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "restricted", broker).
+			SetError(sarama.CoordinatorGroup, "orphaned", sarama.ErrGroupAuthorizationFailed),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t).
+			AddGroupDescription("billing", &sarama.GroupDescription{GroupId: "billing", State: "Stable", ProtocolType: "consumer", Members: member}).
+			AddGroupDescription("abandoned", &sarama.GroupDescription{GroupId: "abandoned", State: "Empty", ProtocolType: "consumer"}).
+			AddGroupDescription("restricted", &sarama.GroupDescription{GroupId: "restricted", ErrorCode: int16(sarama.ErrGroupAuthorizationFailed)}),
+	})
+	// failed coordinator lookups are otherwise retried with backoff
+	conf := sarama.NewConfig()
+	conf.Metadata.Retry.Max = 0
+	connectTestClient(t, broker, conf)
+	defer client.Close()
+	collectionErrors.enabled = true
+	defer func() { collectionErrors.enabled = false }()
+
+	// the groups which failed are kept as their state is unknown, the others still told apart
+	active := activeGroups([]string{"billing", "abandoned", "restricted", "orphaned"})
+	sort.Strings(active)
+	if expected := []string{"billing", "orphaned", "restricted"}; !reflect.DeepEqual(active, expected) {
+		t.Errorf("expected the groups which failed to be kept alongside the active ones, got %v", active)
+	}
+	failed := make(map[string]interface{})
+	for _, event := range collectionErrors.drain() {
+		failed[event["group"].(string)] = event["operation"]
+	}
+	if expected := map[string]interface{}{"orphaned": "coordinator", "restricted": "describeGroups"}; !reflect.DeepEqual(failed, expected) {
+		t.Errorf("expected an error for each group which failed, got %v", failed)
+	}
+}
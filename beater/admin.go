@@ -0,0 +1,43 @@
+package beater
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// admin fetches the offsets groups committed to Kafka when use_admin_client is set, nil to fetch them
+// from the group coordinator directly. It shares the package client, so closing it closes the client
+var admin sarama.ClusterAdmin
+
+// connectAdmin creates the admin client over the package client, leaving admin nil for protocol versions
+// the admin offset listing cannot serve reliably
+func connectAdmin() error {
+	if !client.Config().Version.IsAtLeast(sarama.V0_10_2_0) {
+		logp.Warn("Kafka version %v predates the admin offset API, fetching offsets from the coordinator", client.Config().Version)
+		return nil
+	}
+	var err error
+	admin, err = sarama.NewClusterAdminFromClient(client)
+	return err
+}
+
+// getAdminOffsets returns the offsets a group committed to Kafka for the partitions, listed through the admin client
+func getAdminOffsets(group string, topic string, partitions []int32) (map[int32]int64, error) {
+	offsets := make(map[int32]int64)
+	res, err := admin.ListConsumerGroupOffsets(group, map[string][]int32{topic: partitions})
+	if err == nil && res.Err != sarama.ErrNoError {
+		err = res.Err
+	}
+	if err != nil {
+		collectionErrors.record("listConsumerGroupOffsets", common.MapStr{"topic": topic, "group": group}, err)
+		return offsets, err
+	}
+	for _, pid := range partitions {
+		block := res.GetBlock(topic, pid)
+		if block != nil && block.Err == sarama.ErrNoError && block.Offset > -1 {
+			offsets[pid] = block.Offset
+		}
+	}
+	return offsets, nil
+}
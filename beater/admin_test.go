@@ -0,0 +1,44 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestAdminOffsetsConsumerEvents(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata.SetController(broker.BrokerID()),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 7, "", sarama.ErrNoError).
+			SetOffset("billing", "orders", 1, 3, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+	if err := connectAdmin(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { admin = nil }()
+
+	events := processGroups([]string{"billing"}, "orders", map[int32]int64{0: 10, 1: 5})
+	if len(events) != 2 {
+		t.Fatalf("expected an event per partition, got %v", events)
+	}
+	for _, event := range events {
+		expected := map[int32][2]int64{0: {7, 3}, 1: {3, 2}}[event["partition"].(int32)]
+		if event["offset"] != expected[0] || event["lag"] != expected[1] || event["offsetStore"] != kafkaStore {
+			t.Errorf("expected offset %v and lag %v from kafka, got %v", expected[0], expected[1], event)
+		}
+	}
+	for _, exchange := range broker.History() {
+		if request, ok := exchange.Request.(*sarama.OffsetFetchRequest); ok && request.Version != 2 {
+			t.Errorf("expected the admin client to fetch offsets with v2, got v%v", request.Version)
+		}
+	}
+}
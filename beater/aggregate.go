@@ -0,0 +1,110 @@
+package beater
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+// groupAggregate is a logical group standing for the physical groups whose names match its pattern, as the
+// per shard or per pod groups of one application
+type groupAggregate struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// newGroupAggregates compiles the aggregate_groups entries, each of which needs a name and a pattern
+func newGroupAggregates(confs []config.AggregateConfig) ([]groupAggregate, error) {
+	var aggregates []groupAggregate
+	for _, conf := range confs {
+		if conf.Name == "" || conf.Pattern == "" {
+			return nil, fmt.Errorf("aggregate_groups entries need a name and a pattern")
+		}
+		pattern, err := regexp.Compile(conf.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid aggregate_groups pattern %v: %v", conf.Pattern, err)
+		}
+		aggregates = append(aggregates, groupAggregate{conf.Name, pattern})
+	}
+	return aggregates, nil
+}
+
+// aggregateFor returns the logical group the physical group is aggregated into, the first whose pattern
+// matches it
+func (bt *Kafkabeat) aggregateFor(group string) (string, bool) {
+	for _, aggregate := range bt.groupAggregates {
+		if aggregate.pattern.MatchString(group) {
+			return aggregate.name, true
+		}
+	}
+	return "", false
+}
+
+// aggregateGroups adds a group_topic rollup for each logical group to the topic's rollups, summing the lag
+// of the physical groups it stands for and flagged aggregated with the groups summed. The rollups of the
+// physical groups are left out when suppress_aggregated_groups is set
+func (bt *Kafkabeat) aggregateGroups(topic string, rollups []common.MapStr) []common.MapStr {
+	if len(bt.groupAggregates) == 0 {
+		return rollups
+	}
+	aggregates := make(map[string]common.MapStr)
+	var names []string
+	for _, rollup := range rollups {
+		name, ok := bt.aggregateFor(rollup["group"].(string))
+		if !ok {
+			continue
+		}
+		aggregate, ok := aggregates[name]
+		if !ok {
+			aggregate = common.MapStr{
+				"@timestamp":  common.Time(time.Now()),
+				"type":        "group_topic",
+				"topic":       topic,
+				"group":       name,
+				"aggregated":  true,
+				"groups":      []string{},
+				"totalLag":    int64(0),
+				"lagByLeader": common.MapStr{},
+				"partialData": false,
+			}
+			aggregates[name] = aggregate
+			names = append(names, name)
+		}
+		aggregate["groups"] = append(aggregate["groups"].([]string), rollup["group"].(string))
+		aggregate["totalLag"] = aggregate["totalLag"].(int64) + rollup["totalLag"].(int64)
+		breakdown, _ := rollup["lagByLeader"].(common.MapStr)
+		summed := aggregate["lagByLeader"].(common.MapStr)
+		for leader, lag := range breakdown {
+			previous, _ := summed[leader].(int64)
+			summed[leader] = previous + lag.(int64)
+		}
+		if rollup["partialData"] == true {
+			aggregate["partialData"] = true
+		}
+	}
+	sort.Strings(names)
+	kept := bt.unaggregated(rollups)
+	for _, name := range names {
+		kept = append(kept, aggregates[name])
+	}
+	return kept
+}
+
+// unaggregated leaves out the events of physical groups standing in a logical group when
+// suppress_aggregated_groups is set
+func (bt *Kafkabeat) unaggregated(events []common.MapStr) []common.MapStr {
+	if !bt.suppressAggregated {
+		return events
+	}
+	kept := make([]common.MapStr, 0, len(events))
+	for _, event := range events {
+		if _, ok := bt.aggregateFor(event["group"].(string)); !ok {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
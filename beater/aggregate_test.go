@@ -0,0 +1,69 @@
+package beater
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+func TestAggregateGroups(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest":   sarama.NewMockOffsetResponse(t).SetOffset("orders", 0, sarama.OffsetNewest, 100),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing-shard-0", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "billing-shard-1", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "shipping", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing-shard-0", "orders", 0, 90, "", sarama.ErrNoError).
+			SetOffset("billing-shard-1", "orders", 0, 70, "", sarama.ErrNoError).
+			SetOffset("shipping", "orders", 0, 50, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	for _, suppress := range []bool{false, true} {
+		bt := New()
+		var err error
+		bt.groupAggregates, err = newGroupAggregates([]config.AggregateConfig{{Name: "billing", Pattern: `^billing-shard-\d+$`}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bt.suppressAggregated = suppress
+		bt.topics = []string{"orders"}
+		bt.groups = []string{"billing-shard-0", "billing-shard-1", "shipping"}
+		bt.offsetBasis = highWatermark
+		published := &capturePublisher{}
+		bt.collect(&beat.Beat{Events: published})
+
+		rollups := make(map[interface{}]map[string]interface{})
+		for _, rollup := range published.ofType("group_topic") {
+			rollups[rollup["group"]] = rollup
+		}
+		logical := rollups["billing"]
+		if logical == nil || logical["totalLag"] != int64(40) || logical["aggregated"] != true {
+			t.Fatalf("expected the lag of both shards to sum into the billing rollup, got %v", rollups)
+		}
+		if !reflect.DeepEqual(logical["groups"], []string{"billing-shard-0", "billing-shard-1"}) {
+			t.Errorf("expected the rollup to list the groups summed, got %v", logical["groups"])
+		}
+		if rollups["shipping"] == nil {
+			t.Errorf("expected the unmatched group's rollup, got %v", rollups)
+		}
+		_, physical := rollups["billing-shard-0"]
+		consumers := len(published.ofType("consumer"))
+		if suppress && (physical || consumers != 1) {
+			t.Errorf("expected only the unmatched group's events with suppression, got %v rollups and %v consumer events", rollups, consumers)
+		}
+		if !suppress && (!physical || consumers != 3) {
+			t.Errorf("expected the physical groups' events kept, got %v rollups and %v consumer events", rollups, consumers)
+		}
+	}
+}
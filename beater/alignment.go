@@ -0,0 +1,21 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// how the @timestamp of events is published
+const (
+	exactAlignment  = "exact"
+	periodAlignment = "period"
+)
+
+// alignTimestamp rounds the event's @timestamp down to a multiple of the period, so that successive ticks
+// land on the boundaries of the buckets rollups aggregate over
+func (bt *Kafkabeat) alignTimestamp(event common.MapStr) {
+	if ts, ok := event["@timestamp"].(common.Time); ok {
+		event["@timestamp"] = common.Time(time.Time(ts).Truncate(bt.period))
+	}
+}
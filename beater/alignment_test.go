@@ -0,0 +1,44 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestTimestampAlignment(t *testing.T) {
+	bt := New()
+	bt.period = 10 * time.Second
+	bt.timestampAlignment = periodAlignment
+	published := &capturePublisher{}
+	start := time.Date(2017, 5, 1, 12, 0, 3, 0, time.UTC)
+	for tick := 0; tick < 3; tick++ {
+		at := start.Add(time.Duration(tick)*bt.period + time.Duration(tick)*1700*time.Millisecond)
+		bt.publish(&beat.Beat{Events: published}, []common.MapStr{
+			{"@timestamp": common.Time(at), "type": "topic", "topic": "orders", "partition": int32(0), "size": int64(tick)},
+		})
+	}
+	if len(published.events) != 3 {
+		t.Fatalf("expected an event per tick, got %v", published.events)
+	}
+	for tick, event := range published.events {
+		ts := time.Time(event["@timestamp"].(common.Time))
+		expected := start.Add(time.Duration(tick) * bt.period).Truncate(bt.period)
+		if ts.UnixNano()%int64(bt.period) != 0 || !ts.Equal(expected) {
+			t.Errorf("expected tick %v to be published at the period boundary %v, got %v", tick, expected, ts)
+		}
+	}
+}
+
+func TestTimestampExact(t *testing.T) {
+	bt := New()
+	bt.period = 10 * time.Second
+	at := time.Date(2017, 5, 1, 12, 0, 3, 0, time.UTC)
+	published := &capturePublisher{}
+	bt.publish(&beat.Beat{Events: published}, []common.MapStr{{"@timestamp": common.Time(at), "type": "kafkabeat"}})
+	if ts := time.Time(published.events[0]["@timestamp"].(common.Time)); !ts.Equal(at) {
+		t.Errorf("expected the exact timestamp %v by default, got %v", at, ts)
+	}
+}
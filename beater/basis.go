@@ -0,0 +1,64 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// offsets a partition's size can be read at
+const (
+	// the offset of the last message replicated to all in sync replicas
+	highWatermark = "high_watermark"
+	// the offset below which every transaction has been committed or aborted
+	lastStable = "last_stable"
+)
+
+// basisFor returns the offset basis of the topic, looking in topic_offset_basis, then the topic's settings,
+// falling back to the global offset_basis. A topic_settings entry and topic_offset_basis never both set
+// one topic's, as checkTopicSettings rejects it
+func (bt *Kafkabeat) basisFor(topic string) string {
+	if basis, ok := bt.topicOffsetBasis[topic]; ok {
+		return basis
+	}
+	if basis := bt.topicSettingFor(topic).offsetBasis; basis != "" {
+		return basis
+	}
+	return bt.offsetBasis
+}
+
+func getPartitionSize(topic string, pid int32, basis string) (int64, error) {
+	broker, err := client.Leader(topic, pid)
+	if err != nil {
+		return -1, err
+	}
+	start := time.Now()
+	defer brokerLatencies.since(broker.ID(), start)
+	if basis != lastStable {
+		return client.GetOffset(topic, pid, sarama.OffsetNewest)
+	}
+	// read committed listing needs v2 of the offset request
+	request := &sarama.OffsetRequest{Version: 2, IsolationLevel: sarama.ReadCommitted}
+	request.AddBlock(topic, pid, sarama.OffsetNewest, 1)
+	res, err := broker.GetAvailableOffsets(request)
+	if err != nil {
+		return -1, err
+	}
+	brokerThrottles.record(res.ThrottleTimeMs)
+	block := res.GetBlock(topic, pid)
+	if block == nil {
+		return -1, sarama.ErrIncompleteResponse
+	}
+	if block.Err != sarama.ErrNoError {
+		return -1, block.Err
+	}
+	return block.Offset, nil
+}
+
+func mapValues(values map[string]string) []string {
+	list := make([]string, 0, len(values))
+	for _, value := range values {
+		list = append(list, value)
+	}
+	return list
+}
@@ -0,0 +1,46 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestTopicOffsetBasisOverride(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1, "payments": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10).
+			SetOffset("payments", 0, sarama.OffsetNewest, 20),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+
+	bt := New()
+	bt.offsetBasis = highWatermark
+	bt.topicOffsetBasis = map[string]string{"payments": lastStable}
+
+	isolation := func(topic string) sarama.IsolationLevel {
+		before := len(broker.History())
+		sizes := getPartitionSizes(topic, []int32{0}, bt.basisFor(topic), 1, nil)
+		if len(sizes) != 1 {
+			t.Fatalf("expected the size of %v to be read, got %v", topic, sizes)
+		}
+		history := broker.History()[before:]
+		for i := len(history) - 1; i >= 0; i-- {
+			if request, ok := history[i].Request.(*sarama.OffsetRequest); ok {
+				return request.IsolationLevel
+			}
+		}
+		t.Fatalf("no offset request issued for %v", topic)
+		return 0
+	}
+	if isolation("orders") != sarama.ReadUncommitted {
+		t.Errorf("expected orders to fall back to the high watermark")
+	}
+	if isolation("payments") != sarama.ReadCommitted {
+		t.Errorf("expected payments to be read at the last stable offset")
+	}
+}
@@ -0,0 +1,92 @@
+package beater
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// batchStats counts what a tick published, to size output queues and compression against
+type batchStats struct {
+	events int
+	bytes  int
+}
+
+// add counts the events, estimating their encoded size unless kafkabeat debugging is enabled,
+// in which case they are encoded to measure it exactly
+func (s *batchStats) add(events []common.MapStr) {
+	s.events += len(events)
+	for _, event := range events {
+		if logp.IsDebug("kafkabeat") {
+			encoded, err := json.Marshal(event)
+			if err == nil {
+				s.bytes += len(encoded)
+				continue
+			}
+		}
+		s.bytes += estimateSize(event)
+	}
+}
+
+// publishBatchStats publishes what was published since the last call and resets the counts
+func (bt *Kafkabeat) publishBatchStats(b *beat.Beat) {
+	event := common.MapStr{
+		"@timestamp":      common.Time(time.Now()),
+		"type":            "kafkabeat",
+		"eventsPublished": bt.batch.events,
+		"batchBytes":      bt.batch.bytes,
+		"batchBytesExact": logp.IsDebug("kafkabeat"),
+	}
+	bt.batch = batchStats{}
+	// enriched like every event, but left out of the counts of the next batch
+	events := []common.MapStr{event}
+	bt.enrich(events)
+	bt.send(b, events)
+}
+
+// estimateSize approximates the length of the value encoded as JSON without encoding it
+func estimateSize(value interface{}) int {
+	switch v := value.(type) {
+	case nil:
+		return len("null")
+	case string:
+		return len(v) + 2
+	case bool:
+		return len(fmt.Sprint(v))
+	case common.Time:
+		return len(common.TsLayout) + 2
+	case common.MapStr:
+		return estimateMapSize(v)
+	case map[string]interface{}:
+		return estimateMapSize(v)
+	}
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() == reflect.Slice || reflected.Kind() == reflect.Array {
+		size := 2
+		for i := 0; i < reflected.Len(); i++ {
+			if i > 0 {
+				size++
+			}
+			size += estimateSize(reflected.Index(i).Interface())
+		}
+		return size
+	}
+	return len(fmt.Sprint(value))
+}
+
+func estimateMapSize(fields map[string]interface{}) int {
+	size := 2
+	for key, value := range fields {
+		if size > 2 {
+			size++
+		}
+		// the quoted key and the colon separating it from the value
+		size += len(key) + 3 + estimateSize(value)
+	}
+	return size
+}
@@ -0,0 +1,75 @@
+package beater
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestBatchStatsEstimate(t *testing.T) {
+	events := []common.MapStr{
+		{
+			"@timestamp":         common.Time(time.Now()),
+			"type":               "topic",
+			"partition":          int32(3),
+			"topic":              "orders",
+			"size":               int64(123456),
+			"replicaAssignment":  []int32{1, 2, 3},
+			"leaderNotPreferred": false,
+		},
+		{
+			"@timestamp": common.Time(time.Now()),
+			"type":       "consumer",
+			"partition":  int32(3),
+			"topic":      "orders",
+			"group":      "billing",
+			"offset":     int64(123000),
+			"lag":        int64(456),
+		},
+	}
+	exact := 0
+	for _, event := range events {
+		encoded, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exact += len(encoded)
+	}
+
+	bt := New()
+	bt.batch.add(events)
+	published := &capturePublisher{}
+	bt.publishBatchStats(&beat.Beat{Events: published})
+	stats := published.ofType("kafkabeat")
+	if len(stats) != 1 || stats[0]["eventsPublished"] != 2 {
+		t.Fatalf("expected a stats event counting 2 events, got %v", stats)
+	}
+	estimate := stats[0]["batchBytes"].(int)
+	if estimate < exact*9/10 || estimate > exact*11/10 {
+		t.Errorf("expected an estimate close to the encoded %v bytes, got %v", exact, estimate)
+	}
+	if bt.batch.events != 0 || bt.batch.bytes != 0 {
+		t.Errorf("expected the counts to be reset once published, got %v", bt.batch)
+	}
+}
+
+func TestBatchStatsEnriched(t *testing.T) {
+	bt := New()
+	bt.collector = "collector-1"
+	bt.ticks = 7
+	published := &capturePublisher{}
+	bt.publishBatchStats(&beat.Beat{Events: published})
+	stats := published.ofType("kafkabeat")
+	if len(stats) != 1 {
+		t.Fatalf("expected a stats event, got %v", published.events)
+	}
+	if stats[0]["schemaVersion"] != SchemaVersion || stats[0]["collector"] != "collector-1" || stats[0]["tickSeq"] != 7 {
+		t.Errorf("expected the stats event to carry the fields of every event, got %v", stats[0])
+	}
+	if bt.batch.events != 0 {
+		t.Errorf("expected the stats event not to be counted in the next batch, got %v", bt.batch)
+	}
+}
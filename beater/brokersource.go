@@ -0,0 +1,65 @@
+package beater
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// the lists of brokers broker_source can seed the client with
+const (
+	zookeeperBrokers = "zookeeper"
+	metadataBrokers  = "metadata"
+	unionBrokers     = "union"
+)
+
+// checkBrokerSource checks broker_source names one of the lists of brokers, returning the default when unset
+func checkBrokerSource(source string) (string, error) {
+	switch source {
+	case "":
+		return zookeeperBrokers, nil
+	case zookeeperBrokers, metadataBrokers, unionBrokers:
+		return source, nil
+	}
+	return "", fmt.Errorf("Unknown broker_source %v, expected %v, %v or %v", source, zookeeperBrokers, metadataBrokers, unionBrokers)
+}
+
+// metadataBrokerList lists the brokers the client's metadata reports, in address order
+func metadataBrokerList() []string {
+	var brokers []string
+	for addr := range brokerIds() {
+		brokers = append(brokers, addr)
+	}
+	sort.Strings(brokers)
+	return brokers
+}
+
+// brokerDiscrepancy returns the brokers only registered in Zookeeper and those only the metadata reports,
+// neither when the metadata reports no brokers to compare
+func brokerDiscrepancy(zookeeper []string, metadata []string) ([]string, []string) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	onlyMetadata, onlyZookeeper := diffBrokers(zookeeper, metadata)
+	return onlyZookeeper, onlyMetadata
+}
+
+// chooseBrokers picks the brokers seeding the client from those registered in Zookeeper and those the
+// metadata reports as broker_source says, warning when the two disagree as in transitional setups
+func (bt *Kafkabeat) chooseBrokers(zookeeper []string, metadata []string) []string {
+	onlyZookeeper, onlyMetadata := brokerDiscrepancy(zookeeper, metadata)
+	if len(onlyZookeeper) > 0 || len(onlyMetadata) > 0 {
+		logp.Warn("Zookeeper and the cluster metadata disagree on the brokers, only in Zookeeper: %v, only in the metadata: %v. Seeding the client from %v",
+			onlyZookeeper, onlyMetadata, bt.brokerSource)
+	}
+	switch bt.brokerSource {
+	case metadataBrokers:
+		if len(metadata) > 0 {
+			return metadata
+		}
+	case unionBrokers:
+		return append(append([]string(nil), zookeeper...), onlyMetadata...)
+	}
+	return zookeeper
+}
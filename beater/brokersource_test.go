@@ -0,0 +1,45 @@
+package beater
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestBrokerSource(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{"MetadataRequest": metadata})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	// Zookeeper still lists a broker being decommissioned that the metadata no longer reports
+	retired := "retired:9092"
+	defer func(original func() ([]string, error)) { brokerList = original }(brokerList)
+	brokerList = func() ([]string, error) { return []string{broker.Addr(), retired}, nil }
+
+	onlyZookeeper, onlyMetadata := brokerDiscrepancy([]string{broker.Addr(), retired}, metadataBrokerList())
+	if !reflect.DeepEqual(onlyZookeeper, []string{retired}) || len(onlyMetadata) != 0 {
+		t.Errorf("expected the retired broker to be logged as only in Zookeeper, got %v and %v", onlyZookeeper, onlyMetadata)
+	}
+	expected := map[string][]string{
+		zookeeperBrokers: {broker.Addr(), retired},
+		metadataBrokers:  {broker.Addr()},
+		unionBrokers:     {broker.Addr(), retired},
+	}
+	for source, brokers := range expected {
+		bt := New()
+		bt.brokerSource = source
+		bt.brokers = []string{broker.Addr()}
+		if err := bt.refreshBrokers(&beat.Beat{Events: &capturePublisher{}}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(bt.brokers, brokers) {
+			t.Errorf("expected the %v source to seed the client with %v, got %v", source, brokers, bt.brokers)
+		}
+	}
+	if _, err := checkBrokerSource("dns"); err == nil {
+		t.Errorf("expected an unknown source to be rejected")
+	}
+}
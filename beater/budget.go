@@ -0,0 +1,39 @@
+package beater
+
+import "github.com/elastic/beats/libbeat/common"
+
+// lagBudgetFor returns the lag the group's consumption of the topic is allowed, looking for a budget set
+// for the group and topic, then for the topic, then the global one. 0 when none applies
+func (bt *Kafkabeat) lagBudgetFor(group string, topic string) int64 {
+	if budget, ok := bt.lagBudgets[group+"/"+topic]; ok {
+		return budget
+	}
+	if budget, ok := bt.lagBudgets[topic]; ok {
+		return budget
+	}
+	return bt.lagBudget
+}
+
+// markLagBudgets adds the lag of consumer events, and the total lag of group rollups, as a percentage of
+// the budget that applies to them as lagBudgetPct, so that groups with different tolerances can be
+// compared on one scale. Events over their budget are flagged overBudget
+func (bt *Kafkabeat) markLagBudgets(events []common.MapStr) {
+	for _, event := range events {
+		lag, ok := lagOf(event)
+		if event["type"] == "group_topic" {
+			lag, ok = event["totalLag"].(int64)
+		}
+		if !ok {
+			continue
+		}
+		budget := bt.lagBudgetFor(event["group"].(string), event["topic"].(string))
+		if budget <= 0 {
+			continue
+		}
+		pct := float64(lag) / float64(budget) * 100
+		event["lagBudgetPct"] = pct
+		if pct > 100 {
+			event["overBudget"] = true
+		}
+	}
+}
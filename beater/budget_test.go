@@ -0,0 +1,30 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestLagBudgets(t *testing.T) {
+	bt := New()
+	bt.lagBudget = 1000
+	bt.lagBudgets = map[string]int64{"orders": 200, "billing/orders": 50}
+	events := []common.MapStr{
+		{"type": "consumer", "group": "billing", "topic": "orders", "lag": int64(75), "logEndOffset": int64(100)},
+		{"type": "consumer", "group": "shipping", "topic": "orders", "lag": int64(50), "logEndOffset": int64(100)},
+		{"type": "consumer", "group": "shipping", "topic": "clicks", "lag": int64(500), "logEndOffset": int64(900)},
+		{"type": "consumer", "group": "shipping", "topic": "clicks", "lag": unknownOffset, "logEndOffset": unknownOffset},
+		{"type": "group_topic", "group": "billing", "topic": "orders", "totalLag": int64(40)},
+	}
+	bt.markLagBudgets(events)
+	expected := []struct {
+		pct  interface{}
+		over bool
+	}{{150.0, true}, {25.0, false}, {50.0, false}, {nil, false}, {80.0, false}}
+	for i, event := range events {
+		if event["lagBudgetPct"] != expected[i].pct || (event["overBudget"] == true) != expected[i].over {
+			t.Errorf("expected %v%% of the budget, over it %v, got %v", expected[i].pct, expected[i].over, event)
+		}
+	}
+}
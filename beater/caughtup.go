@@ -0,0 +1,33 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// markCaughtUp adds how long each group has had no lag on the topic to its rollup events
+func (bt *Kafkabeat) markCaughtUp(rollups []common.MapStr) {
+	bt.trackCaughtUp(rollups, time.Now())
+}
+
+// trackCaughtUp adds caughtUpSeconds to the rollups, the time since the group was first seen with no lag
+// on the topic on every partition holding data. It is 0 while the group lags, the streak restarting once
+// it catches up again
+func (bt *Kafkabeat) trackCaughtUp(rollups []common.MapStr, now time.Time) {
+	for _, event := range rollups {
+		key := stateKey("caughtUp", event["group"], event["topic"])
+		if event["totalLag"] != int64(0) || event["partialData"] == true {
+			bt.state.Delete(key)
+			event["caughtUpSeconds"] = float64(0)
+			continue
+		}
+		since := now
+		if previous, ok := bt.state.Get(key); ok {
+			since = previous.(time.Time)
+		} else {
+			bt.state.Put(key, since)
+		}
+		event["caughtUpSeconds"] = now.Sub(since).Seconds()
+	}
+}
@@ -0,0 +1,35 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestCaughtUpSeconds(t *testing.T) {
+	bt := New()
+	start := time.Now()
+	ticks := []struct {
+		lag      int64
+		partial  bool
+		expected float64
+	}{
+		{5, false, 0},
+		{0, false, 0},
+		{0, false, 10},
+		{0, false, 20},
+		{3, false, 0},
+		{0, false, 0},
+		{0, true, 0},
+		{0, false, 0},
+		{0, false, 10},
+	}
+	for i, tick := range ticks {
+		event := common.MapStr{"type": "group_topic", "group": "billing", "topic": "orders", "totalLag": tick.lag, "partialData": tick.partial}
+		bt.trackCaughtUp([]common.MapStr{event}, start.Add(time.Duration(i)*10*time.Second))
+		if event["caughtUpSeconds"] != tick.expected {
+			t.Errorf("expected tick %v with a lag of %v to have been caught up for %vs, got %v", i, tick.lag, tick.expected, event["caughtUpSeconds"])
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package beater
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+const defaultClusterIdField = "clusterId"
+
+// clusterId reads the id of the cluster from a broker's metadata, empty for Kafka versions before 0.10.1
+// which don't report one
+var clusterId = func() (string, error) {
+	if !client.Config().Version.IsAtLeast(sarama.V0_10_1_0) {
+		return "", nil
+	}
+	broker := client.LeastLoadedBroker()
+	if broker == nil {
+		return "", sarama.ErrOutOfBrokers
+	}
+	if err := broker.Open(client.Config()); err != nil && err != sarama.ErrAlreadyConnected {
+		return "", err
+	}
+	request := sarama.NewMetadataRequest(client.Config().Version, nil)
+	if request.Version < 2 {
+		// v2 is the first version to carry the cluster id
+		request.Version = 2
+	}
+	res, err := broker.GetMetadata(request)
+	if err != nil {
+		return "", err
+	}
+	if res.ClusterID == nil {
+		return "", nil
+	}
+	return *res.ClusterID, nil
+}
+
+// addClusterId names the cluster the event was collected from, telling apart the events of clusters
+// shipping to one index. Nothing is added when the cluster reported no id
+func (bt *Kafkabeat) addClusterId(event common.MapStr) {
+	if bt.clusterId != "" {
+		event[bt.clusterIdField] = bt.clusterId
+	}
+}
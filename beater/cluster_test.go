@@ -0,0 +1,70 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestClusterId(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	id := "lkc-2x7q"
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": newMetadataWrapper(func(metadata *sarama.MetadataResponse) {
+			metadata.ClusterID = &id
+			metadata.AddBroker(broker.Addr(), broker.BrokerID())
+			metadata.AddTopicPartition("orders", 0, 1, []int32{1}, []int32{1}, nil, sarama.ErrNoError)
+		}),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).SetOffset("orders", 0, sarama.OffsetNewest, 10),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 4, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	bt := New()
+	var err error
+	bt.clusterId, err = clusterId()
+	if err != nil || bt.clusterId != id {
+		t.Fatalf("expected the cluster id %v from the metadata, got %v: %v", id, bt.clusterId, err)
+	}
+	bt.topics = []string{"orders"}
+	bt.groups = []string{"billing"}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	published := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: published})
+	for _, eventType := range []string{"topic", "consumer"} {
+		events := published.ofType(eventType)
+		if len(events) != 1 || events[0]["clusterId"] != id {
+			t.Errorf("expected the %v event to carry the cluster id, got %v", eventType, events)
+		}
+	}
+}
+
+func TestClusterIdUnsupported(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{"MetadataRequest": metadata})
+	conf := sarama.NewConfig()
+	conf.Version = sarama.V0_10_0_0
+	connectTestClient(t, broker, conf)
+	defer client.Close()
+
+	bt := New()
+	if id, err := clusterId(); err != nil || id != "" {
+		t.Errorf("expected no cluster id before 0.10.1, got %q: %v", id, err)
+	}
+	event := common.MapStr{"type": "topic"}
+	bt.addClusterId(event)
+	if _, ok := event["clusterId"]; ok {
+		t.Errorf("expected the field to be omitted without a cluster id, got %v", event)
+	}
+}
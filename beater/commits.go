@@ -0,0 +1,55 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// commitHistory is when a group was seen to commit new offsets for a partition while owned by a member
+type commitHistory struct {
+	offset  int64
+	member  interface{}
+	since   time.Time
+	commits []time.Time
+}
+
+// markCommitRate adds how often the group commits new offsets for each partition to its consumer events
+func (bt *Kafkabeat) markCommitRate(events []common.MapStr) {
+	bt.trackCommits(events, time.Now())
+}
+
+// trackCommits counts the ticks a partition's committed offset moved over the last minute, or the last
+// five periods when those are longer, and adds them to the events as commitsPerMinute. Counting restarts
+// when the partition is reassigned to another member
+func (bt *Kafkabeat) trackCommits(events []common.MapStr, now time.Time) {
+	window := time.Minute
+	if 5*bt.period > window {
+		window = 5 * bt.period
+	}
+	for _, event := range events {
+		key := stateKey("commits", seriesKey(event))
+		offset := event["offset"].(int64)
+		previous, ok := bt.state.Get(key)
+		if !ok || previous.(*commitHistory).member != event["memberId"] {
+			bt.state.Put(key, &commitHistory{offset: offset, member: event["memberId"], since: now})
+			continue
+		}
+		history := previous.(*commitHistory)
+		if offset != history.offset {
+			history.offset = offset
+			history.commits = append(history.commits, now)
+		}
+		for len(history.commits) > 0 && !history.commits[0].After(now.Add(-window)) {
+			history.commits = history.commits[1:]
+		}
+		bt.state.Put(key, history)
+		observed := now.Sub(history.since)
+		if observed > window {
+			observed = window
+		}
+		if observed > 0 {
+			event["commitsPerMinute"] = float64(len(history.commits)) / observed.Minutes()
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestCommitsPerMinute(t *testing.T) {
+	bt := New()
+	bt.period = 10 * time.Second
+	start := time.Now()
+	consumer := func(offset int64, member string) common.MapStr {
+		return common.MapStr{"type": "consumer", "group": "billing", "topic": "orders", "partition": int32(0), "offset": offset, "memberId": member}
+	}
+
+	var last common.MapStr
+	// committing on 4 of the 6 ticks following the first, one minute's worth at a 10s period
+	for tick, offset := range []int64{0, 10, 10, 20, 30, 30, 40} {
+		last = consumer(offset, "consumer-1")
+		bt.trackCommits([]common.MapStr{last}, start.Add(time.Duration(tick)*bt.period))
+		if _, ok := last["commitsPerMinute"]; tick == 0 && ok {
+			t.Errorf("expected no commit rate on the first sighting, got %v", last)
+		}
+	}
+	if last["commitsPerMinute"] != 4.0 {
+		t.Errorf("expected 4 commits per minute, got %v", last["commitsPerMinute"])
+	}
+
+	reassigned := consumer(50, "consumer-2")
+	bt.trackCommits([]common.MapStr{reassigned}, start.Add(7*bt.period))
+	if _, ok := reassigned["commitsPerMinute"]; ok {
+		t.Errorf("expected the count to restart when the partition is reassigned, got %v", reassigned)
+	}
+}
@@ -0,0 +1,26 @@
+package beater
+
+import "github.com/elastic/beats/libbeat/common"
+
+// compactConsumers drops the consumer events of the groups whose total lag on the topic is below
+// compact_lag_below, their rollup events standing in for them flagged compacted. The groups lagging past
+// it keep their per partition detail to diagnose with
+func (bt *Kafkabeat) compactConsumers(consumers []common.MapStr, rollups []common.MapStr) []common.MapStr {
+	if bt.compactLagBelow <= 0 {
+		return consumers
+	}
+	compacted := make(map[string]bool)
+	for _, rollup := range rollups {
+		if rollup["totalLag"].(int64) < bt.compactLagBelow {
+			compacted[rollup["group"].(string)] = true
+			rollup["compacted"] = true
+		}
+	}
+	detailed := make([]common.MapStr, 0, len(consumers))
+	for _, event := range consumers {
+		if !compacted[event["group"].(string)] {
+			detailed = append(detailed, event)
+		}
+	}
+	return detailed
+}
@@ -0,0 +1,68 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestCompactLagBelow(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2})
+	defer broker.Close()
+	offsets := sarama.NewMockOffsetFetchResponse(t).
+		SetOffset("billing", "orders", 0, 9, "", sarama.ErrNoError).
+		SetOffset("billing", "orders", 1, 9, "", sarama.ErrNoError).
+		SetOffset("audit", "orders", 0, 2, "", sarama.ErrNoError).
+		SetOffset("audit", "orders", 1, 3, "", sarama.ErrNoError)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10).
+			SetOffset("orders", 1, sarama.OffsetNewest, 10),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "audit", broker),
+		"OffsetFetchRequest":    offsets,
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	bt := New()
+	bt.topics = []string{"orders"}
+	bt.groups = []string{"audit", "billing"}
+	bt.offsetBasis = highWatermark
+	bt.compactLagBelow = 5
+	events := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: events})
+
+	// billing lags by 2 in total, audit by 15
+	for _, event := range events.ofType("consumer") {
+		if event["group"] != "audit" {
+			t.Errorf("expected per partition detail only for the group lagging past the threshold, got %v", event)
+		}
+	}
+	if consumers := events.ofType("consumer"); len(consumers) != 2 {
+		t.Errorf("expected audit's partitions to be detailed, got %v", consumers)
+	}
+	rollups := events.ofType("group_topic")
+	if len(rollups) != 2 {
+		t.Fatalf("expected a rollup per group, got %v", rollups)
+	}
+	for _, rollup := range rollups {
+		compacted := rollup["group"] == "billing"
+		if _, ok := rollup["compacted"]; ok != compacted {
+			t.Errorf("expected only billing's rollup to stand in for its detail, got %v", rollup)
+		}
+	}
+
+	// once billing falls behind its detail is back
+	offsets.SetOffset("billing", "orders", 0, 1, "", sarama.ErrNoError)
+	events = &capturePublisher{}
+	bt.collect(&beat.Beat{Events: events})
+	if consumers := events.ofType("consumer"); len(consumers) != 4 {
+		t.Errorf("expected both groups' partitions to be detailed, got %v", consumers)
+	}
+}
@@ -0,0 +1,68 @@
+package beater
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// consumedTopics fetches every offset the group committed to Kafka in a single request, returning the
+// topics it consumes. false when they couldn't be fetched, as from brokers older than 0.10.2
+func consumedTopics(group string) (map[string]bool, bool) {
+	version := offsetFetchVersion()
+	if version < 2 {
+		// v2 is the first version to fetch every partition when none are asked for
+		return nil, false
+	}
+	broker, err := coordinatorFor(group)
+	if err != nil {
+		logp.Debug("kafkabeat", "Unable to identify group coordinator for group %v: %v", group, err)
+		collectionErrors.record("coordinator", common.MapStr{"group": group}, err)
+		return nil, false
+	}
+	request := sarama.OffsetFetchRequest{ConsumerGroup: group, Version: version}
+	request.ZeroPartitions()
+	res, err := broker.FetchOffset(&request)
+	if err == nil && res.Err != sarama.ErrNoError {
+		err = res.Err
+	}
+	if err != nil {
+		logp.Debug("kafkabeat", "Unable to fetch the topics group %s consumes: %v", group, err)
+		collectionErrors.record("fetchOffset", common.MapStr{"group": group}, err)
+		invalidateCoordinator(group)
+		return nil, false
+	}
+	brokerThrottles.record(res.ThrottleTimeMs)
+	topics := make(map[string]bool)
+	for topic, partitions := range res.Blocks {
+		for _, block := range partitions {
+			if block.Err == sarama.ErrNoError && block.Offset > -1 {
+				topics[topic] = true
+			}
+		}
+	}
+	return topics, true
+}
+
+// groupsConsuming keeps the groups that committed offsets to Kafka for the topic, so that offsets aren't
+// fetched for groups that never touch it. The topics each group consumes are fetched once per refresh period.
+// Groups with no offsets in Kafka, found in Zookeeper too, or whose topics couldn't be fetched are kept, as
+// their offsets may be in another store
+func (bt *Kafkabeat) groupsConsuming(groups []string, topic string) []string {
+	var consuming []string
+	for _, group := range groups {
+		topics, ok := bt.consumption[group]
+		if !ok {
+			topics, ok = consumedTopics(group)
+			if !ok {
+				consuming = append(consuming, group)
+				continue
+			}
+			bt.consumption[group] = topics
+		}
+		if len(topics) == 0 || duplicateGroups[group] || topics[topic] {
+			consuming = append(consuming, group)
+		}
+	}
+	return consuming
+}
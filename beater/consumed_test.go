@@ -0,0 +1,52 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestConsumedTopicsOnly(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1, "clicks": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10).
+			SetOffset("clicks", 0, sarama.OffsetNewest, 10),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 4, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	bt := New()
+	bt.topics = []string{"orders", "clicks"}
+	bt.groups = []string{"billing"}
+	bt.offsetBasis = highWatermark
+	bt.consumedTopicsOnly = true
+	for tick := 0; tick < 2; tick++ {
+		events := &capturePublisher{}
+		bt.collect(&beat.Beat{Events: events})
+		consumers := events.ofType("consumer")
+		if len(consumers) != 1 || consumers[0]["topic"] != "orders" {
+			t.Fatalf("expected a consumer event for orders only, got %v", consumers)
+		}
+	}
+
+	// one fetch of every offset of the group, then one per tick for the topic it consumes and none for clicks
+	fetches := 0
+	for _, exchange := range broker.History() {
+		if _, ok := exchange.Request.(*sarama.OffsetFetchRequest); ok {
+			fetches++
+		}
+	}
+	if fetches != 3 {
+		t.Errorf("expected 3 offset fetches, got %v", fetches)
+	}
+}
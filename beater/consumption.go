@@ -0,0 +1,70 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// groupTopicEvents builds an event per group with committed offsets for the topic, counting the partitions
+// it has them for, so that which groups consume which topics can be graphed. Each also rolls up the group's
+// lag on the topic, flagged partialData when offsets were not found for every partition holding data, and the
+// assignor the group's members use when it is known
+func groupTopicEvents(topic string, pids map[int32]int64, consumers []common.MapStr) []common.MapStr {
+	// groups reported per store have an event per store for the same partition, the first is counted
+	lags := make(map[string]map[int32]int64)
+	fetched := make(map[string]map[int32]bool)
+	assignors := make(map[string]interface{})
+	var groups []string
+	for _, event := range consumers {
+		group := event["group"].(string)
+		if lags[group] == nil {
+			lags[group] = make(map[int32]int64)
+			fetched[group] = make(map[int32]bool)
+			groups = append(groups, group)
+		}
+		if assignor, ok := event["assignor"]; ok {
+			assignors[group] = assignor
+		}
+		pid := event["partition"].(int32)
+		if !fetched[group][pid] {
+			fetched[group][pid] = true
+			// a partition whose log end is unknown has no lag to add up, so isn't counted as having offsets
+			if lag, ok := lagOf(event); ok {
+				lags[group][pid] = lag
+			}
+		}
+	}
+	total := len(partitionsWithData(pids))
+	events := make([]common.MapStr, 0, len(groups))
+	for _, group := range groups {
+		totalLag := int64(0)
+		for _, lag := range lags[group] {
+			totalLag += lag
+		}
+		event := common.MapStr{
+			"@timestamp":            common.Time(time.Now()),
+			"type":                  "group_topic",
+			"topic":                 topic,
+			"group":                 group,
+			"partitionsWithOffsets": len(lags[group]),
+			"totalLag":              totalLag,
+			"partitionsFetched":     len(fetched[group]),
+			"partitionsTotal":       total,
+			"partialData":           len(lags[group]) < total,
+		}
+		if assignor, ok := assignors[group]; ok {
+			event["assignor"] = assignor
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// markHasConsumers flags the topic's events with whether any group committed offsets for the topic,
+// surfacing topics holding data nobody consumes
+func markHasConsumers(events []common.MapStr, consumers []common.MapStr) {
+	for _, event := range events {
+		event["hasConsumers"] = len(consumers) > 0
+	}
+}
@@ -0,0 +1,176 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestGroupTopicEvents(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2, "payments": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10).
+			SetOffset("orders", 1, sarama.OffsetNewest, 10).
+			SetOffset("payments", 0, sarama.OffsetNewest, 10),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "audit", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 4, "", sarama.ErrNoError).
+			SetOffset("billing", "orders", 1, 5, "", sarama.ErrNoError).
+			SetOffset("audit", "orders", 1, 2, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	bt := New()
+	bt.topics = []string{"orders", "payments"}
+	bt.groups = []string{"audit", "billing"}
+	bt.offsetBasis = highWatermark
+	events := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: events})
+
+	relationships := events.ofType("group_topic")
+	if len(relationships) != 2 {
+		t.Fatalf("expected a relationship per group consuming orders, got %v", relationships)
+	}
+	for _, event := range relationships {
+		expected := map[string]int{"billing": 2, "audit": 1}[event["group"].(string)]
+		if event["topic"] != "orders" || event["partitionsWithOffsets"] != expected {
+			t.Errorf("expected %v to have offsets for %v partitions of orders, got %v", event["group"], expected, event)
+		}
+	}
+}
+
+func TestGroupTopicPartialData(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 3})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		// the block for partition 2 errored
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 4, "", sarama.ErrNoError).
+			SetOffset("billing", "orders", 1, 5, "", sarama.ErrNoError).
+			SetOffset("billing", "orders", 2, -1, "", sarama.ErrNotCoordinatorForConsumer),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	sizes := map[int32]int64{0: 10, 1: 10, 2: 10}
+	rollups := groupTopicEvents("orders", sizes, processGroups([]string{"billing"}, "orders", sizes))
+	if len(rollups) != 1 {
+		t.Fatalf("expected a rollup for the group, got %v", rollups)
+	}
+	rollup := rollups[0]
+	if rollup["partitionsFetched"] != 2 || rollup["partitionsTotal"] != 3 || rollup["partialData"] != true {
+		t.Errorf("expected the rollup to be flagged partial with 2 of 3 partitions, got %v", rollup)
+	}
+	if rollup["totalLag"] != int64(11) {
+		t.Errorf("expected the lag of the fetched partitions to total 11, got %v", rollup["totalLag"])
+	}
+}
+
+func TestGroupTopicUnknownLogEnd(t *testing.T) {
+	consumers := []common.MapStr{
+		{"type": "consumer", "group": "billing", "topic": "orders", "partition": int32(0), "offset": int64(4), "lag": int64(6), "logEndOffset": int64(10)},
+		// the size of partition 1 couldn't be read
+		{"type": "consumer", "group": "billing", "topic": "orders", "partition": int32(1), "offset": int64(5), "lag": unknownOffset, "logEndOffset": unknownOffset},
+	}
+	rollups := groupTopicEvents("orders", map[int32]int64{0: 10}, consumers)
+	if len(rollups) != 1 {
+		t.Fatalf("expected a group_topic event, got %v", rollups)
+	}
+	rollup := rollups[0]
+	if rollup["totalLag"] != int64(6) || rollup["partitionsWithOffsets"] != 1 || rollup["partitionsFetched"] != 2 {
+		t.Errorf("expected the partition without a log end left out of the lag, got %v", rollup)
+	}
+}
+
+func TestHasConsumers(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1, "orphaned": 2})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10).
+			SetOffset("orphaned", 0, sarama.OffsetNewest, 10).
+			SetOffset("orphaned", 1, sarama.OffsetNewest, 10),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 4, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	bt := New()
+	bt.topics = []string{"orders", "orphaned"}
+	bt.groups = []string{"billing"}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	events := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: events})
+
+	topics := events.ofType("topic")
+	if len(topics) != 3 {
+		t.Fatalf("expected an event per partition, got %v", topics)
+	}
+	for _, event := range topics {
+		expected := event["topic"] == "orders"
+		if event["hasConsumers"] != expected {
+			t.Errorf("expected partition %v of %v to have hasConsumers %v, got %v", event["partition"], event["topic"], expected, event)
+		}
+	}
+}
+
+func TestAssignor(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 4, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockWrapper(&sarama.DescribeGroupsResponse{
+			Groups: []*sarama.GroupDescription{{
+				GroupId:      "billing",
+				State:        "Stable",
+				ProtocolType: "consumer",
+				Protocol:     "cooperative-sticky",
+				Members: map[string]*sarama.GroupMemberDescription{
+					"member": {
+						MemberId:         "member",
+						MemberAssignment: encodeAssignment(map[string][]int32{"orders": {0}}),
+					},
+				},
+			}},
+		}),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	sizes := map[int32]int64{0: 10}
+	consumers := processGroups([]string{"billing"}, "orders", sizes)
+	if len(consumers) != 1 || consumers[0]["assignor"] != "cooperative-sticky" {
+		t.Fatalf("expected the consumer event to name the group's assignor, got %v", consumers)
+	}
+	rollups := groupTopicEvents("orders", sizes, consumers)
+	if len(rollups) != 1 || rollups[0]["assignor"] != "cooperative-sticky" {
+		t.Errorf("expected the rollup to name the group's assignor, got %v", rollups)
+	}
+}
@@ -0,0 +1,41 @@
+package beater
+
+import (
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// staleCoordinators are the groups whose coordinator failed a request, as when the coordinator moves
+var staleCoordinators = struct {
+	sync.Mutex
+	groups map[string]bool
+}{groups: make(map[string]bool)}
+
+// coordinatorFor returns the group's coordinator. The client caches coordinators so that groups aren't
+// looked up every tick, a group's being looked up again only once invalidated
+func coordinatorFor(group string) (*sarama.Broker, error) {
+	staleCoordinators.Lock()
+	stale := staleCoordinators.groups[group]
+	delete(staleCoordinators.groups, group)
+	staleCoordinators.Unlock()
+	if stale {
+		if err := client.RefreshCoordinator(group); err != nil {
+			logp.Debug("kafkabeat", "Unable to look up the coordinator of group %s again: %v", group, err)
+		}
+	}
+	return client.Coordinator(group)
+}
+
+// invalidateCoordinator has the group's coordinator looked up again on its next request
+func invalidateCoordinator(group string) {
+	staleCoordinators.Lock()
+	defer staleCoordinators.Unlock()
+	staleCoordinators.groups[group] = true
+}
+
+// movedCoordinator is whether the error means the group is no longer coordinated by the broker asked
+func movedCoordinator(err sarama.KError) bool {
+	return err == sarama.ErrNotCoordinatorForConsumer || err == sarama.ErrConsumerCoordinatorNotAvailable
+}
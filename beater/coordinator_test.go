@@ -0,0 +1,51 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestCoordinatorCached(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	offsets := sarama.NewMockOffsetFetchResponse(t).
+		SetOffset("billing", "orders", 0, 4, "", sarama.ErrNoError)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest":    offsets,
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+	lookups := func() int {
+		found := 0
+		for _, exchange := range broker.History() {
+			if _, ok := exchange.Request.(*sarama.FindCoordinatorRequest); ok {
+				found++
+			}
+		}
+		return found
+	}
+
+	for tick := 0; tick < 3; tick++ {
+		processGroups([]string{"billing"}, "orders", map[int32]int64{0: 10})
+	}
+	if found := lookups(); found != 1 {
+		t.Errorf("expected the coordinator to be looked up once across ticks, got %v lookups", found)
+	}
+
+	// the coordinator moving fails the fetch, so the next tick looks it up again
+	offsets.SetOffset("billing", "orders", 0, -1, "", sarama.ErrNotCoordinatorForConsumer)
+	processGroups([]string{"billing"}, "orders", map[int32]int64{0: 10})
+	offsets.SetOffset("billing", "orders", 0, 4, "", sarama.ErrNoError)
+	for tick := 0; tick < 2; tick++ {
+		processGroups([]string{"billing"}, "orders", map[int32]int64{0: 10})
+	}
+	if found := lookups(); found != 2 {
+		t.Errorf("expected the coordinator to be looked up again only after the error, got %v lookups", found)
+	}
+}
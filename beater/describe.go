@@ -0,0 +1,66 @@
+package beater
+
+import (
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// groupDescriptions are the descriptions of the groups described this pass, so that a group consuming many
+// topics is described once a pass rather than once for each of its topics. nil outside of a pass
+var groupDescriptions = struct {
+	sync.Mutex
+	groups map[string]*sarama.GroupDescription
+}{}
+
+// describeGroup returns the group's description, asking its coordinator only the first time the group is
+// described in the pass. Failures aren't kept, the group being described again for its next topic
+func describeGroup(group string) (*sarama.GroupDescription, error) {
+	groupDescriptions.Lock()
+	description, ok := groupDescriptions.groups[group]
+	groupDescriptions.Unlock()
+	if ok {
+		return description, nil
+	}
+	broker, err := coordinatorFor(group)
+	if err != nil {
+		return nil, err
+	}
+	request := sarama.DescribeGroupsRequest{Groups: []string{group}}
+	if client.Config().Version.IsAtLeast(sarama.V2_4_0_0) {
+		// v4 is the first version to carry the group instance ids of static members
+		request.Version = 4
+	}
+	res, err := broker.DescribeGroups(&request)
+	if err != nil {
+		invalidateCoordinator(group)
+		return nil, err
+	}
+	brokerThrottles.record(res.ThrottleTimeMs)
+	for _, description := range res.Groups {
+		if description.Err != sarama.ErrNoError {
+			if movedCoordinator(description.Err) {
+				invalidateCoordinator(group)
+			}
+			return nil, description.Err
+		}
+		groupDescriptions.Lock()
+		if groupDescriptions.groups != nil {
+			groupDescriptions.groups[group] = description
+		}
+		groupDescriptions.Unlock()
+		return description, nil
+	}
+	return &sarama.GroupDescription{GroupId: group}, nil
+}
+
+// keepGroupDescriptions starts keeping the groups described at the start of a pass, and drops them at its end
+// so that every group is described again the next pass
+func keepGroupDescriptions(keep bool) {
+	groupDescriptions.Lock()
+	defer groupDescriptions.Unlock()
+	groupDescriptions.groups = nil
+	if keep {
+		groupDescriptions.groups = make(map[string]*sarama.GroupDescription)
+	}
+}
@@ -0,0 +1,38 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// scheduleDriftEvent builds the event reporting how late the tick received at now is against the ticker's
+// schedule, flagged paused when later than the pause threshold. A tick held back by the previous tick
+// overrunning is measured from when that tick finished, as the overrun is reported apart, so that the drift
+// is down to the beat's own process stalling e.g. in a GC pause or starved of CPU. Delays up to
+// period_jitter are the ticker's own and not counted
+func (bt *Kafkabeat) scheduleDriftEvent(now time.Time) common.MapStr {
+	expected := bt.tickDue
+	if bt.tickDone.After(expected) {
+		expected = bt.tickDone
+	}
+	// ticks fire up to period_jitter late on purpose
+	drift := now.Sub(expected) - bt.periodJitter
+	if drift < 0 {
+		drift = 0
+	}
+	for !bt.tickDue.After(now) {
+		bt.tickDue = bt.tickDue.Add(bt.period)
+	}
+	paused := drift > bt.pauseThreshold
+	if paused {
+		logp.Warn("Tick fired %v late, kafkabeat may have been paused", drift)
+	}
+	return common.MapStr{
+		"@timestamp":      common.Time(time.Now()),
+		"type":            "kafkabeat",
+		"scheduleDriftMs": milliseconds(drift),
+		"paused":          paused,
+	}
+}
@@ -0,0 +1,33 @@
+package beater
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleDrift(t *testing.T) {
+	bt := New()
+	bt.period = time.Second
+	bt.pauseThreshold = 200 * time.Millisecond
+	start := time.Now()
+	bt.tickDue = start.Add(bt.period)
+
+	event := bt.scheduleDriftEvent(start.Add(bt.period + 50*time.Millisecond))
+	if event["scheduleDriftMs"] != milliseconds(50*time.Millisecond) || event["paused"] != false {
+		t.Errorf("expected a 50ms drift below the threshold, got %v", event)
+	}
+	// the process stalled for half a second past the second tick
+	event = bt.scheduleDriftEvent(start.Add(2*bt.period + 500*time.Millisecond))
+	if event["scheduleDriftMs"] != milliseconds(500*time.Millisecond) || event["paused"] != true {
+		t.Errorf("expected a 500ms drift flagged paused, got %v", event)
+	}
+	// the third tick was held back by the one before it overrunning, which is not drift
+	bt.tickDone = start.Add(3*bt.period + 700*time.Millisecond)
+	event = bt.scheduleDriftEvent(start.Add(3*bt.period + 710*time.Millisecond))
+	if event["scheduleDriftMs"] != milliseconds(10*time.Millisecond) || event["paused"] != false {
+		t.Errorf("expected the overrun not to count as drift, got %v", event)
+	}
+	if !bt.tickDue.Equal(start.Add(4 * bt.period)) {
+		t.Errorf("expected the next tick to be due on schedule, got %v", bt.tickDue.Sub(start))
+	}
+}
@@ -0,0 +1,24 @@
+package beater
+
+import "github.com/elastic/beats/libbeat/common"
+
+// markLagEma adds the exponential moving average of each partition's lag to its consumer events as lagEma,
+// steadying the lag of bursty consumers for dashboards and alerts. The average starts at the first lag seen
+func (bt *Kafkabeat) markLagEma(events []common.MapStr) {
+	if bt.lagEmaAlpha == 0 {
+		return
+	}
+	for _, event := range events {
+		lag, ok := lagOf(event)
+		if !ok {
+			continue
+		}
+		key := stateKey("lagEma", seriesKey(event))
+		ema := float64(lag)
+		if previous, ok := bt.state.Get(key); ok {
+			ema = bt.lagEmaAlpha*float64(lag) + (1-bt.lagEmaAlpha)*previous.(float64)
+		}
+		bt.state.Put(key, ema)
+		event["lagEma"] = ema
+	}
+}
@@ -0,0 +1,31 @@
+package beater
+
+import (
+	"math"
+	"testing"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestLagEma(t *testing.T) {
+	bt := New()
+	bt.lagEmaAlpha = 0.5
+	expected := []float64{100, 50, 125, 62.5, 131.25}
+	for i, lag := range []int64{100, 0, 200, 0, 200} {
+		event := common.MapStr{"type": "consumer", "group": "billing", "topic": "orders", "partition": int32(0), "lag": lag}
+		bt.markLagEma([]common.MapStr{event})
+		if ema := event["lagEma"].(float64); math.Abs(ema-expected[i]) > 1e-9 {
+			t.Errorf("expected the lag EMA after %v to be %v, got %v", lag, expected[i], ema)
+		}
+		if event["lag"] != lag {
+			t.Errorf("expected the raw lag to be kept, got %v", event["lag"])
+		}
+	}
+
+	bt = New()
+	event := common.MapStr{"type": "consumer", "group": "billing", "topic": "orders", "partition": int32(0), "lag": int64(5)}
+	bt.markLagEma([]common.MapStr{event})
+	if _, ok := event["lagEma"]; ok {
+		t.Errorf("expected no smoothing without an alpha, got %v", event)
+	}
+}
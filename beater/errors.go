@@ -0,0 +1,96 @@
+package beater
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// errorLog buffers the errors a tick hits calling the brokers so they can be published as error events,
+// building a timeline of intermittent cluster issues. Nothing is buffered unless emit_errors is set
+type errorLog struct {
+	enabled bool
+	// identical errors within the window are collapsed into one event, 0 to publish every error
+	window time.Duration
+	events []common.MapStr
+	// the collapsed errors in the order first hit, by operation, group, topic and error
+	collapsing []*collapsedError
+	collapsed  map[string]*collapsedError
+	lock       sync.Mutex
+}
+
+// collapsedError counts the occurrences of an error on its event until the window closes
+type collapsedError struct {
+	key    string
+	event  common.MapStr
+	closes time.Time
+}
+
+var collectionErrors = &errorLog{}
+
+// record buffers an error event for the failed operation, identified by the topic, group and partition
+// fields it was called for
+func (l *errorLog) record(operation string, fields common.MapStr, err error) {
+	if err == nil {
+		return
+	}
+	collectionStats.Add("rpcErrors", 1)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if !l.enabled {
+		return
+	}
+	event := common.MapStr{
+		"@timestamp": common.Time(time.Now()),
+		"type":       "error",
+		"operation":  operation,
+		"error":      err.Error(),
+	}
+	event.Update(fields)
+	if l.window <= 0 {
+		l.events = append(l.events, event)
+		return
+	}
+	key := stateKey(operation, fields["group"], fields["topic"], err.Error())
+	if collapsed, ok := l.collapsed[key]; ok {
+		collapsed.event["occurrences"] = collapsed.event["occurrences"].(int) + 1
+		return
+	}
+	if l.collapsed == nil {
+		l.collapsed = make(map[string]*collapsedError)
+	}
+	event["occurrences"] = 1
+	collapsed := &collapsedError{key, event, time.Now().Add(l.window)}
+	l.collapsed[key] = collapsed
+	l.collapsing = append(l.collapsing, collapsed)
+}
+
+// drain returns the error events buffered since it was last called, holding back collapsed errors
+// until their window closes
+func (l *errorLog) drain() []common.MapStr {
+	return l.drainAt(time.Now())
+}
+
+// flush returns every buffered error event, collapsed errors included, for when no more ticks follow
+func (l *errorLog) flush() []common.MapStr {
+	return l.drainAt(time.Now().Add(l.window))
+}
+
+func (l *errorLog) drainAt(now time.Time) []common.MapStr {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	events := l.events
+	l.events = nil
+	open := l.collapsing[:0]
+	for _, collapsed := range l.collapsing {
+		if collapsed.closes.After(now) {
+			open = append(open, collapsed)
+			continue
+		}
+		events = append(events, collapsed.event)
+		delete(l.collapsed, collapsed.key)
+	}
+	l.collapsing = open
+	return events
+}
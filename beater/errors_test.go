@@ -0,0 +1,87 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestEmitErrors(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetError(sarama.CoordinatorGroup, "billing", sarama.ErrGroupAuthorizationFailed),
+	})
+	// failed coordinator lookups are otherwise retried with backoff
+	conf := sarama.NewConfig()
+	conf.Metadata.Retry.Max = 0
+	connectTestClient(t, broker, conf)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+	collectionErrors.enabled = true
+	defer func() { collectionErrors.enabled = false }()
+
+	if events := processGroups([]string{"billing"}, "orders", map[int32]int64{0: 10}); len(events) != 0 {
+		t.Fatalf("expected no consumer events without a coordinator, got %v", events)
+	}
+	bt := New()
+	bt.suppressUnchanged = true
+	published := &capturePublisher{}
+	bt.publish(&beat.Beat{Events: published}, collectionErrors.drain())
+	errors := published.ofType("error")
+	if len(errors) == 0 {
+		t.Fatalf("expected an error event for the failed coordinator lookup")
+	}
+	event := errors[0]
+	if event["operation"] != "coordinator" || event["group"] != "billing" || event["topic"] != "orders" ||
+		event["error"] != sarama.ErrGroupAuthorizationFailed.Error() {
+		t.Errorf("expected the coordinator lookup of group billing to have failed, got %v", event)
+	}
+
+	// every error is published, however alike
+	processGroups([]string{"billing"}, "orders", map[int32]int64{0: 10})
+	bt.publish(&beat.Beat{Events: published}, collectionErrors.drain())
+	if again := published.ofType("error"); len(again) != 2*len(errors) {
+		t.Errorf("expected the repeated error to be published again, got %v", again)
+	}
+
+	collectionErrors.enabled = false
+	processGroups([]string{"billing"}, "orders", map[int32]int64{0: 10})
+	if buffered := collectionErrors.drain(); len(buffered) != 0 {
+		t.Errorf("expected no errors buffered unless enabled, got %v", buffered)
+	}
+}
+
+func TestErrorDedupeWindow(t *testing.T) {
+	errors := &errorLog{enabled: true, window: time.Minute}
+	failure := sarama.ErrNotCoordinatorForConsumer
+	for i := 0; i < 3; i++ {
+		errors.record("fetchOffset", common.MapStr{"group": "billing", "topic": "orders"}, failure)
+	}
+	errors.record("fetchOffset", common.MapStr{"group": "shipping", "topic": "orders"}, failure)
+	if events := errors.drain(); len(events) != 0 {
+		t.Fatalf("expected the errors to be held back until the window closes, got %v", events)
+	}
+
+	events := errors.drainAt(time.Now().Add(time.Minute))
+	if len(events) != 2 {
+		t.Fatalf("expected an event per distinct error, got %v", events)
+	}
+	if events[0]["group"] != "billing" || events[0]["occurrences"] != 3 {
+		t.Errorf("expected the repeated error to collapse into 3 occurrences, got %v", events[0])
+	}
+	if events[1]["group"] != "shipping" || events[1]["occurrences"] != 1 {
+		t.Errorf("expected the other group's error to be kept apart, got %v", events[1])
+	}
+
+	// the window having closed, the error starts a new one
+	errors.record("fetchOffset", common.MapStr{"group": "billing", "topic": "orders"}, failure)
+	if flushed := errors.flush(); len(flushed) != 1 || flushed[0]["occurrences"] != 1 {
+		t.Errorf("expected a flush to publish the open window, got %v", flushed)
+	}
+}
@@ -0,0 +1,38 @@
+package beater
+
+import (
+	"expvar"
+	"net"
+	"net/http"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// collectionStats counts what the beat has done since it started, served as the kafkabeat map of the
+// expvar endpoint when expvar_bind is set
+var collectionStats = expvar.NewMap("kafkabeat")
+
+func init() {
+	// published as zero until first counted
+	for _, name := range []string{"ticks", "skippedTicks", "events", "rpcErrors", "reconnects"} {
+		collectionStats.Add(name, 0)
+	}
+}
+
+// serveExpvar serves the expvar variables at /debug/vars on the address until the process exits,
+// returning the address listened on
+func serveExpvar(bind string) (net.Addr, error) {
+	listener, err := net.Listen("tcp", bind)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	logp.Info("Serving collection stats on http://%s/debug/vars", listener.Addr())
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			logp.Err("Stopped serving collection stats: %v", err)
+		}
+	}()
+	return listener.Addr(), nil
+}
@@ -0,0 +1,69 @@
+package beater
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func collectionStat(name string) int64 {
+	var value int64
+	json.Unmarshal([]byte(collectionStats.Get(name).String()), &value)
+	return value
+}
+
+func TestCollectionStats(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest":   sarama.NewMockOffsetResponse(t).SetOffset("orders", 0, sarama.OffsetNewest, 10),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetError(sarama.CoordinatorGroup, "billing", sarama.ErrGroupAuthorizationFailed),
+	})
+	// failed coordinator lookups are otherwise retried with backoff
+	conf := sarama.NewConfig()
+	conf.Metadata.Retry.Max = 0
+	connectTestClient(t, broker, conf)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	ticks, events, rpcErrors := collectionStat("ticks"), collectionStat("events"), collectionStat("rpcErrors")
+	bt := New()
+	bt.topics = []string{"orders"}
+	bt.groups = []string{"billing"}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	bt.tick(&beat.Beat{Events: &capturePublisher{}})
+
+	if collectionStat("ticks") != ticks+1 {
+		t.Errorf("expected the tick to be counted, got %v after %v", collectionStat("ticks"), ticks)
+	}
+	if collectionStat("events") <= events {
+		t.Errorf("expected the topic event to be counted, got %v after %v", collectionStat("events"), events)
+	}
+	if collectionStat("rpcErrors") <= rpcErrors {
+		t.Errorf("expected the failed coordinator lookup to be counted, got %v after %v", collectionStat("rpcErrors"), rpcErrors)
+	}
+
+	addr, err := serveExpvar("localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Get("http://" + addr.String() + "/debug/vars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var vars map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&vars); err != nil {
+		t.Fatal(err)
+	}
+	var stats map[string]int64
+	if err := json.Unmarshal(vars["kafkabeat"], &stats); err != nil || stats["ticks"] != collectionStat("ticks") {
+		t.Errorf("expected the endpoint to serve the tick count, got %s", vars["kafkabeat"])
+	}
+}
@@ -0,0 +1,47 @@
+package beater
+
+import (
+	"sort"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// how a topic's metrics are published
+const (
+	perPartition = "per_partition"
+	perTopic     = "per_topic"
+)
+
+// perTopicEvent folds a topic's partition and consumer events into a single event holding an entry per
+// partition with its size, leader and the lag of each group consuming it
+func perTopicEvent(topic string, partitions []common.MapStr, consumers []common.MapStr) common.MapStr {
+	entries := make(map[int32]common.MapStr)
+	var pids []int
+	for _, event := range partitions {
+		pid := event["partition"].(int32)
+		entry := common.MapStr{"partition": pid, "logSize": event["size"], "lag": common.MapStr{}}
+		if leader, ok := event["leader"]; ok && leader != unknownBroker {
+			entry["leader"] = leader
+		}
+		entries[pid] = entry
+		pids = append(pids, int(pid))
+	}
+	for _, event := range consumers {
+		entry, ok := entries[event["partition"].(int32)]
+		if lag, known := lagOf(event); ok && known {
+			entry["lag"].(common.MapStr)[event["group"].(string)] = lag
+		}
+	}
+	sort.Ints(pids)
+	nested := make([]common.MapStr, 0, len(pids))
+	for _, pid := range pids {
+		nested = append(nested, entries[int32(pid)])
+	}
+	return common.MapStr{
+		"@timestamp": common.Time(time.Now()),
+		"type":       "topic",
+		"topic":      topic,
+		"partitions": nested,
+	}
+}
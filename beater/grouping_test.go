@@ -0,0 +1,60 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestPerTopicGrouping(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 3})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10).
+			SetOffset("orders", 1, sarama.OffsetNewest, 20).
+			SetOffset("orders", 2, sarama.OffsetNewest, 30),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 4, "", sarama.ErrNoError).
+			SetOffset("billing", "orders", 1, 15, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	bt := New()
+	bt.topics = []string{"orders"}
+	bt.groups = []string{"billing"}
+	bt.offsetBasis = highWatermark
+	bt.grouping = perTopic
+	published := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: published})
+
+	if consumers := published.ofType("consumer"); len(consumers) != 0 {
+		t.Errorf("expected consumer metrics to be folded into the topic event, got %v", consumers)
+	}
+	topics := published.ofType("topic")
+	if len(topics) != 1 {
+		t.Fatalf("expected a single topic event, got %v", topics)
+	}
+	partitions := topics[0]["partitions"].([]common.MapStr)
+	if len(partitions) != 3 {
+		t.Fatalf("expected an entry per partition, got %v", partitions)
+	}
+	for i, entry := range partitions {
+		pid := int32(i)
+		if entry["partition"] != pid || entry["logSize"] != int64(10*(i+1)) || entry["leader"] != broker.BrokerID() {
+			t.Errorf("expected partition %v's size and leader, got %v", pid, entry)
+		}
+		lag, ok := map[int32]int64{0: 6, 1: 5}[pid]
+		if found, has := entry["lag"].(common.MapStr)["billing"]; has != ok || (ok && found != lag) {
+			t.Errorf("expected partition %v's lag for billing to be %v, got %v", pid, lag, entry["lag"])
+		}
+	}
+}
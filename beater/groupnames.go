@@ -0,0 +1,44 @@
+package beater
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// compileGroupNamePattern compiles group_name_pattern, whose named captures become the fields group names
+// are split into
+func compileGroupNamePattern(pattern string) (*regexp.Regexp, error) {
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid group_name_pattern %v: %v", pattern, err)
+	}
+	for _, name := range compiled.SubexpNames() {
+		if name != "" {
+			return compiled, nil
+		}
+	}
+	return nil, fmt.Errorf("group_name_pattern %v has no named captures to add as fields", pattern)
+}
+
+// addGroupNameFields adds the named captures of group_name_pattern on the event's group as fields, such as
+// the service and env of groups namespaced svc:env:group. The fields are left empty for groups the
+// pattern does not match, so that every event of a group carries them
+func (bt *Kafkabeat) addGroupNameFields(event common.MapStr) {
+	group, ok := event["group"].(string)
+	if !ok {
+		return
+	}
+	match := bt.groupNamePattern.FindStringSubmatch(group)
+	for i, name := range bt.groupNamePattern.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		value := ""
+		if match != nil {
+			value = match[i]
+		}
+		event[name] = value
+	}
+}
@@ -0,0 +1,38 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestGroupNameFields(t *testing.T) {
+	bt := New()
+	var err error
+	bt.groupNamePattern, err = compileGroupNamePattern(`^(?P<service>[^:]+):(?P<env>[^:]+):`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := &capturePublisher{}
+	bt.publish(&beat.Beat{Events: published}, []common.MapStr{
+		{"type": "consumer", "group": "billing:prod:invoices", "topic": "orders", "partition": int32(0), "lag": int64(5)},
+		{"type": "group_topic", "group": "legacy-billing", "topic": "orders", "totalLag": int64(5)},
+		{"type": "topic", "topic": "orders", "partition": int32(0), "size": int64(10)},
+	})
+	if consumer := published.events[0]; consumer["service"] != "billing" || consumer["env"] != "prod" {
+		t.Errorf("expected the service and env captured from the group name, got %v", consumer)
+	}
+	if rollup := published.events[1]; rollup["service"] != "" || rollup["env"] != "" {
+		t.Errorf("expected empty fields for a group the pattern does not match, got %v", rollup)
+	}
+	if _, ok := published.events[2]["service"]; ok {
+		t.Errorf("expected events without a group to be left alone, got %v", published.events[2])
+	}
+}
+
+func TestGroupNamePatternNeedsCaptures(t *testing.T) {
+	if _, err := compileGroupNamePattern(`^[^:]+:`); err == nil {
+		t.Error("expected a pattern without named captures to be rejected")
+	}
+}
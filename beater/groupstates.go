@@ -0,0 +1,30 @@
+package beater
+
+import "fmt"
+
+// the states DescribeGroups reports groups in, AwaitingSync being what brokers before 2.0 call
+// CompletingRebalance
+var knownGroupStates = map[string]bool{"Empty": true, "PreparingRebalance": true, "CompletingRebalance": true,
+	"AwaitingSync": true, "Stable": true, "Dead": true}
+
+// groupStates are the states of the groups whose offsets committed to Kafka produce consumer events, nil
+// for all. Offsets mid-rebalance are transient and can mislead
+var groupStates map[string]bool
+
+// useGroupStates sets the consumer_group_states allowlist, rejecting states the brokers don't report
+func useGroupStates(states []string) error {
+	groupStates = make(map[string]bool)
+	for _, state := range states {
+		if !knownGroupStates[state] {
+			return fmt.Errorf("Unknown consumer group state %v", state)
+		}
+		groupStates[state] = true
+	}
+	return nil
+}
+
+// groupStateIncluded is whether the consumer events of a group in the state are published. Groups whose
+// state couldn't be described are, as are all groups when no allowlist is set
+func groupStateIncluded(state string) bool {
+	return groupStates == nil || state == "" || groupStates[state]
+}
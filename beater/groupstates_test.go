@@ -0,0 +1,54 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestGroupStates(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2})
+	defer broker.Close()
+	describe := func(group string, state string) interface{} {
+		return sarama.NewMockWrapper(&sarama.DescribeGroupsResponse{
+			Groups: []*sarama.GroupDescription{{GroupId: group, State: state, ProtocolType: "consumer"}},
+		})
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "shipping", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 4, "", sarama.ErrNoError).
+			SetOffset("shipping", "orders", 0, 6, "", sarama.ErrNoError),
+		// groups are processed in name order
+		"DescribeGroupsRequest": sarama.NewMockSequence(describe("billing", "Stable"), describe("shipping", "PreparingRebalance")),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	// shipping still commits partition 1 to Zookeeper, which has no say in its state
+	defer stubZookeeperOffsets(map[string]map[string]map[int32]int64{"shipping": {"orders": {1: 7}}})()
+	if err := useGroupStates([]string{"Stable"}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { groupStates = nil }()
+
+	consumers := processGroups([]string{"billing", "shipping"}, "orders", map[int32]int64{0: 10, 1: 10})
+	var partitions []interface{}
+	for _, event := range consumers {
+		if event["group"] == "shipping" {
+			partitions = append(partitions, event["partition"])
+		}
+	}
+	if len(partitions) != 1 || partitions[0] != int32(1) {
+		t.Errorf("expected the rebalancing group's Kafka offsets to be left out and its Zookeeper ones kept, got %v", consumers)
+	}
+	if len(consumers) != 2 || consumers[0]["group"] != "billing" {
+		t.Errorf("expected the stable group's consumer event, got %v", consumers)
+	}
+
+	if err := useGroupStates([]string{"Rebalancing"}); err == nil {
+		t.Errorf("expected an unknown state to be rejected")
+	}
+}
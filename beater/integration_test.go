@@ -0,0 +1,131 @@
+//go:build integration
+// +build integration
+
+package beater
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+// kafkaHost is the broker the integration tests run against, the one of tests/integration by default
+func kafkaHost() string {
+	if host := os.Getenv("KAFKA_HOST"); host != "" {
+		return host
+	}
+	return "localhost:9092"
+}
+
+// connectKafka points the package client at the integration broker, waiting for it to come up
+func connectKafka(t *testing.T) {
+	conf := sarama.NewConfig()
+	conf.Version = sarama.V2_0_0_0
+	conf.Producer.Return.Successes = true
+	conf.Producer.Partitioner = sarama.NewManualPartitioner
+	conf.Consumer.Offsets.AutoCommit.Enable = false
+	deadline := time.Now().Add(time.Minute)
+	for {
+		var err error
+		client, err = sarama.NewClient([]string{kafkaHost()}, conf)
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unable to reach kafka at %v, start it with make kafka-integration-tests: %v", kafkaHost(), err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func TestIntegrationCollect(t *testing.T) {
+	connectKafka(t)
+	defer client.Close()
+	restore := offsetStores
+	defer func() { offsetStores = restore }()
+	if err := useOffsetStores([]string{kafkaStore}); err != nil {
+		t.Fatal(err)
+	}
+
+	topic := fmt.Sprintf("kafkabeat-integration-%v", time.Now().UnixNano())
+	group := topic + "-billing"
+	clusterAdmin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = clusterAdmin.CreateTopic(topic, &sarama.TopicDetail{NumPartitions: 2, ReplicationFactor: 1}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterAdmin.DeleteTopic(topic)
+
+	// 10 messages on partition 0 and 5 on partition 1
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pid, count := range map[int32]int{0: 10, 1: 5} {
+		for i := 0; i < count; i++ {
+			_, _, err := producer.SendMessage(&sarama.ProducerMessage{Topic: topic, Partition: pid, Value: sarama.StringEncoder("order")})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	producer.Close()
+
+	// the group has consumed 4 messages of partition 0 and all of partition 1
+	offsetManager, err := sarama.NewOffsetManagerFromClient(group, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var partitionManagers []sarama.PartitionOffsetManager
+	for pid, offset := range map[int32]int64{0: 4, 1: 5} {
+		partitionManager, err := offsetManager.ManagePartition(topic, pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		partitionManager.MarkOffset(offset, "")
+		partitionManagers = append(partitionManagers, partitionManager)
+	}
+	offsetManager.Commit()
+	for _, partitionManager := range partitionManagers {
+		partitionManager.Close()
+	}
+	offsetManager.Close()
+
+	bt := New()
+	bt.topics = []string{topic}
+	bt.groups = []string{group}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	published := &capturePublisher{}
+	if err := bt.collect(&beat.Beat{Events: published}); err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := map[int32]int64{0: 10, 1: 5}
+	topics := published.ofType("topic")
+	if len(topics) != 2 {
+		t.Fatalf("expected a topic event per partition, got %v", topics)
+	}
+	for _, event := range topics {
+		if pid := event["partition"].(int32); event["size"] != sizes[pid] {
+			t.Errorf("expected partition %v to have a size of %v, got %v", pid, sizes[pid], event)
+		}
+	}
+	lags := map[int32]int64{0: 6, 1: 0}
+	consumers := published.ofType("consumer")
+	if len(consumers) != 2 {
+		t.Fatalf("expected a consumer event per partition, got %v", consumers)
+	}
+	for _, event := range consumers {
+		if pid := event["partition"].(int32); event["group"] != group || event["lag"] != lags[pid] {
+			t.Errorf("expected %v to lag %v on partition %v, got %v", group, lags[pid], pid, event)
+		}
+	}
+}
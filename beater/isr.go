@@ -0,0 +1,63 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// isrChangeEvents builds an event for each partition of the topic whose in sync replicas differ from those
+// last seen, with the replicas that dropped out as shrunk and those that caught up as expanded. A shrinking
+// ISR tends to precede a broker falling over. Partitions seen for the first time are not reported
+func (bt *Kafkabeat) isrChangeEvents(topic string, pids map[int32]int64) []common.MapStr {
+	var events []common.MapStr
+	for _, pid := range sortedPartitions(pids) {
+		isr, err := client.InSyncReplicas(topic, pid)
+		// the ISR is still known while replicas are offline
+		if err != nil && err != sarama.ErrReplicaNotAvailable {
+			logp.Err("Unable to identify in sync replicas for partition %v and topic %s", pid, topic)
+			collectionErrors.record("isr", common.MapStr{"topic": topic, "partition": pid}, err)
+			continue
+		}
+		key := stateKey("isr", topic, pid)
+		previous, seen := bt.state.Get(key)
+		bt.state.Put(key, isr)
+		if !seen {
+			continue
+		}
+		shrunk := missingReplicas(previous.([]int32), isr)
+		expanded := missingReplicas(isr, previous.([]int32))
+		if len(shrunk) == 0 && len(expanded) == 0 {
+			continue
+		}
+		logp.Info("ISR of partition %v of topic %s changed from %v to %v", pid, topic, previous, isr)
+		events = append(events, common.MapStr{
+			"@timestamp":  common.Time(time.Now()),
+			"type":        "isr_change",
+			"topic":       topic,
+			"partition":   pid,
+			"isr":         isr,
+			"previousIsr": previous,
+			"shrunk":      shrunk,
+			"expanded":    expanded,
+		})
+	}
+	return events
+}
+
+// missingReplicas are the replicas of from not in to, never nil so that events always carry the list
+func missingReplicas(from []int32, to []int32) []int32 {
+	kept := make(map[int32]bool)
+	for _, replica := range to {
+		kept[replica] = true
+	}
+	missing := []int32{}
+	for _, replica := range from {
+		if !kept[replica] {
+			missing = append(missing, replica)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,90 @@
+package beater
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestIsrChangeEvents(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	isrs := [][]int32{{1, 2}, {1, 2}, {1, 3}}
+	var responses []interface{}
+	for _, isr := range isrs {
+		isr := isr
+		responses = append(responses, newMetadataWrapper(func(metadata *sarama.MetadataResponse) {
+			metadata.AddBroker(broker.Addr(), broker.BrokerID())
+			metadata.AddTopicPartition("orders", 0, 1, []int32{1, 2, 3}, isr, nil, sarama.ErrNoError)
+		}))
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockSequence(responses...),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+
+	bt := New()
+	pids := map[int32]int64{0: 10}
+	if events := bt.isrChangeEvents("orders", pids); len(events) != 0 {
+		t.Errorf("expected the first ISR seen not to be reported, got %v", events)
+	}
+	if err := client.RefreshMetadata("orders"); err != nil {
+		t.Fatal(err)
+	}
+	if events := bt.isrChangeEvents("orders", pids); len(events) != 0 {
+		t.Errorf("expected an unchanged ISR not to be reported, got %v", events)
+	}
+	if err := client.RefreshMetadata("orders"); err != nil {
+		t.Fatal(err)
+	}
+	events := bt.isrChangeEvents("orders", pids)
+	if len(events) != 1 {
+		t.Fatalf("expected an isr_change event once the ISR changed, got %v", events)
+	}
+	event := events[0]
+	if event["type"] != "isr_change" || event["partition"] != int32(0) {
+		t.Errorf("expected an isr_change event for partition 0, got %v", event)
+	}
+	if !reflect.DeepEqual(event["shrunk"], []int32{2}) || !reflect.DeepEqual(event["expanded"], []int32{3}) {
+		t.Errorf("expected replica 2 to have dropped out and 3 to have caught up, got %v", event)
+	}
+}
+
+func TestIsrChangesNotSuppressed(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	// the ISR of the partition shrinks, then grows back
+	isrs := [][]int32{{1, 2}, {1}, {1, 2}}
+	var responses []interface{}
+	for _, isr := range isrs {
+		isr := isr
+		responses = append(responses, newMetadataWrapper(func(metadata *sarama.MetadataResponse) {
+			metadata.AddBroker(broker.Addr(), broker.BrokerID())
+			metadata.AddTopicPartition("orders", 0, 1, []int32{1, 2}, isr, nil, sarama.ErrNoError)
+		}))
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockSequence(responses...),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+
+	bt := New()
+	bt.suppressUnchanged = true
+	bt.maxSuppressTicks = defaultMaxSuppressTicks
+	published := &capturePublisher{}
+	b := &beat.Beat{Events: published}
+	pids := map[int32]int64{0: 10}
+	for range isrs {
+		bt.publish(b, bt.isrChangeEvents("orders", pids))
+		if err := client.RefreshMetadata("orders"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if changes := published.ofType("isr_change"); len(changes) != 2 {
+		t.Errorf("expected both ISR changes of the partition to be published with suppress_unchanged, got %v", changes)
+	}
+}
@@ -0,0 +1,64 @@
+package beater
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// periodJitterFor reads period_jitter, either a fraction of the period or a duration, which must be shorter
+// than the period for ticks to keep their order
+func periodJitterFor(configured string, period time.Duration) (time.Duration, error) {
+	if configured == "" {
+		return 0, nil
+	}
+	jitter, err := time.ParseDuration(configured)
+	if err != nil {
+		fraction, parseErr := strconv.ParseFloat(configured, 64)
+		if parseErr != nil {
+			return 0, fmt.Errorf("Invalid period_jitter %v, expected a fraction of the period or a duration", configured)
+		}
+		jitter = time.Duration(fraction * float64(period))
+	}
+	if jitter < 0 || jitter >= period {
+		return 0, fmt.Errorf("period_jitter %v must be at least 0 and shorter than the period of %v", configured, period)
+	}
+	return jitter, nil
+}
+
+// newTicker fires every period, each tick delayed by a random part of the jitter so that instances sharing
+// brokers spread their load rather than all collecting on the same boundary. Without jitter it is a plain
+// ticker. Like one, a tick fired while the last is yet to be received is dropped
+func newTicker(period time.Duration, jitter time.Duration) (<-chan time.Time, func()) {
+	if jitter <= 0 {
+		ticker := time.NewTicker(period)
+		return ticker.C, ticker.Stop
+	}
+	ticks := make(chan time.Time, 1)
+	stop := make(chan struct{})
+	offsets := rand.New(rand.NewSource(time.Now().UnixNano()))
+	go func() {
+		due := time.Now()
+		for {
+			due = due.Add(period)
+			timer := time.NewTimer(jitteredTick(due, jitter, offsets).Sub(time.Now()))
+			select {
+			case <-stop:
+				timer.Stop()
+				return
+			case fired := <-timer.C:
+				select {
+				case ticks <- fired:
+				default:
+				}
+			}
+		}
+	}()
+	return ticks, func() { close(stop) }
+}
+
+// jitteredTick is when the tick due at the time on the period's schedule fires, up to the jitter later
+func jitteredTick(due time.Time, jitter time.Duration, offsets *rand.Rand) time.Time {
+	return due.Add(time.Duration(offsets.Int63n(int64(jitter))))
+}
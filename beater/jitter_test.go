@@ -0,0 +1,48 @@
+package beater
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestPeriodJitter(t *testing.T) {
+	period, jitter := time.Second, 200*time.Millisecond
+	offsets := rand.New(rand.NewSource(1))
+	start := time.Now()
+	previous := start
+	intervals := make(map[time.Duration]bool)
+	for tick := 1; tick <= 20; tick++ {
+		fired := jitteredTick(start.Add(time.Duration(tick)*period), jitter, offsets)
+		interval := fired.Sub(previous)
+		if interval < period-jitter || interval > period+jitter {
+			t.Errorf("expected tick %v to fire within %v of the period of %v, %v after the last", tick, jitter, period, interval)
+		}
+		intervals[interval] = true
+		previous = fired
+	}
+	if len(intervals) < 2 {
+		t.Errorf("expected the intervals between ticks to vary, got %v", intervals)
+	}
+
+	ticks, stop := newTicker(20*time.Millisecond, 10*time.Millisecond)
+	defer stop()
+	for tick := 0; tick < 3; tick++ {
+		select {
+		case <-ticks:
+		case <-time.After(time.Second):
+			t.Fatalf("expected the jittered ticker to keep ticking, tick %v never came", tick)
+		}
+	}
+
+	for configured, expected := range map[string]time.Duration{"": 0, "0.2": 200 * time.Millisecond, "150ms": 150 * time.Millisecond} {
+		if found, err := periodJitterFor(configured, period); err != nil || found != expected {
+			t.Errorf("expected period_jitter %q to be %v, got %v %v", configured, expected, found, err)
+		}
+	}
+	for _, configured := range []string{"1s", "1.5", "-0.1", "often"} {
+		if _, err := periodJitterFor(configured, period); err == nil {
+			t.Errorf("expected period_jitter %v to be rejected", configured)
+		}
+	}
+}
@@ -4,10 +4,19 @@ import (
 	"fmt"
 	"time"
 	"strconv"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"sync"
+	"syscall"
 	"github.com/elastic/beats/libbeat/beat"
 	"github.com/elastic/beats/libbeat/cfgfile"
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/publisher"
 	"github.com/wvanbergen/kazoo-go"
 	"github.com/Shopify/sarama"
 	"github.com/gingerwizard/kafkabeat/config"
@@ -16,6 +25,38 @@ import (
 var client sarama.Client
 var zClient *kazoo.Kazoo
 
+// brokerList reads the brokers currently registered in Zookeeper
+var brokerList = func() ([]string, error) {
+	return zClient.BrokerList()
+}
+
+// zookeeperGroups lists the consumer groups registered in Zookeeper
+var zookeeperGroups = func() ([]string, error) {
+	group_list, err := zClient.Consumergroups()
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]string, len(group_list))
+	for i, group := range group_list {
+		groups[i] = group.Name
+	}
+	return groups, nil
+}
+
+// zookeeperOffset reads the offset a group committed to Zookeeper for a partition, -1 if it has none
+var zookeeperOffset = func(group string, topic string, pid int32) (int64, error) {
+	return zClient.Consumergroup(group).FetchOffset(topic, pid)
+}
+
+// readConfig reads the config file the beat is run with
+var readConfig = cfgfile.Read
+
+var printResolved *bool
+
+func init() {
+	printResolved = flag.Bool("resolve", false, "Print the resolved brokers, topics and groups as JSON and exit")
+}
+
 type KafkabeatError struct {
 	error string
 }
@@ -23,50 +64,220 @@ type KafkabeatError struct {
 type Kafkabeat struct {
 	beatConfig *config.Config
 	done       chan struct{}
-	period     time.Duration
+	// closed when Run returns, which Stop waits for so that Cleanup doesn't close the clients under it
+	finished chan struct{}
+	// closing cuts a collection pass short, done until the final pass run once stopped
+	cutoff <-chan struct{}
+	// how long the final pass run once stopped may take, 0 to run none
+	finalTickTimeout time.Duration
+	period           time.Duration
+	// up to how much later than the period's schedule each tick fires at random
+	periodJitter time.Duration
+	// how late a tick may fire before the beat is flagged paused, 0 to not measure tick drift
+	pauseThreshold time.Duration
+	// when the next tick is due on the ticker's schedule and when the last one finished
+	tickDue  time.Time
+	tickDone time.Time
+	// which of the brokers registered in Zookeeper and those the metadata reports seed the client
+	brokerSource string
+	// how often the broker list is re-read from Zookeeper, unless the topology of a stable cluster is
+	// only refreshed on SIGHUP or once calls to the brokers fail
+	refreshPeriod time.Duration
+	stableCluster bool
 
 	topics     [] string
 	groups     [] string
 	zookeepers    [] string
 	brokers [] string
 	create_topic_docs bool
+	// whether groups are discovered rather than configured
+	discoverGroups bool
+	// how many groups a tick processes at most, and the group the next tick starts from
+	maxGroupsPerTick int
+	groupCursor      int
+	// whether topics are discovered rather than configured, which of them are monitored and whether
+	// changes to them are watched for in Zookeeper rather than polled on refresh
+	discoverTopics bool
+	topicPrefix    string
+	topicWatch     bool
+	// how long a discovered topic is present before it is monitored, and since when each has been
+	newTopicGrace     time.Duration
+	topicsSeen        map[string]time.Time
+	suppressUnchanged bool
+	maxSuppressTicks  int
+	// offset log sizes are read at, globally and per topic
+	offsetBasis      string
+	topicOffsetBasis map[string]string
+	// partitions watched in place of the monitored topics and groups, by topic and group
+	watch map[string]map[string][]int32
+
+	// state kept across ticks, bounded by state_cache_size
+	state *stateCache
+	// how long a lagging group's offset may sit unchanged before its events are tagged stale, 0 to never
+	maxOffsetAge     time.Duration
+	suppressStaleLag bool
+	// whether a tick ends by publishing what it published, and the counts so far
+	reportBatchStats bool
+	// how many partitions are sized at once, and whether and how far it is raised when ticks run long
+	concurrency    int
+	autoTune       bool
+	maxConcurrency int
+	// clients event types are published through instead of the beat's own, by event type
+	pipelines map[string]publisher.Client
+	// the lag above which consumer events are flagged, globally and by topic or group/topic
+	lagThreshold  int64
+	lagThresholds map[string]int64
+	// the highest lag published, 0 for no cap
+	lagCap int64
+	// the lag consumption is expected to stay within, by group/topic or topic, for lagBudgetPct
+	lagBudget  int64
+	lagBudgets map[string]int64
+	// how heavily the lag of each tick weighs in the smoothed lagEma, 0 to leave lag unsmoothed
+	lagEmaAlpha float64
+	// the total lag on a topic below which a group's per partition events are left out
+	compactLagBelow int64
+	// how long after a group's offsets are reset its consumer events leave out the alert fields
+	offsetResetGrace time.Duration
+	// how long a group's commits on a topic may sit still while it commits on others before it is taken to
+	// have dropped the topic, 0 to never, and whether the alert fields are then left out
+	abandonedAfter    time.Duration
+	suppressAbandoned bool
+	// the total size below which a topic's events are left out, 0 to publish every topic
+	minTopicSize int64
+	// the partitions of wide topics per partition events are published for
+	sampling sampling
+	// per topic overrides of the settings above, and the defaults they are merged over
+	topicSettings []topicSetting
+	topicDefaults topicSetting
+	// logical groups rolling up the lag of the physical groups matching them, and whether the physical
+	// groups' own events are left out
+	groupAggregates    []groupAggregate
+	suppressAggregated bool
+	// whether discovery leaves out the groups the brokers coordinate that have no members
+	activeGroupsOnly bool
+	// whether discovered groups differing only by case or surrounding whitespace are monitored as one
+	normalizeGroupNames bool
+	// where the gauges of every tick are pushed to, if anywhere
+	pushGateway *pushGateway
+	// the index prefixes the events of topics are indexed under instead of the configured index
+	topicIndexes []topicIndex
+	// whether topic events carry the bytes partitions take up on disk
+	partitionBytes bool
+	// whether the age of the oldest message retained on each topic is published
+	topicAge bool
+	// the offsets, besides the size, partition events carry each in a field of its own
+	offsetSpecs []offsetSpec
+	// whether a topic is published as an event per partition or a single event
+	grouping string
+	// whether the @timestamp of events is published exact or rounded down to the period
+	timestampAlignment string
+	// splits group names into fields by its named captures, nil to leave them whole
+	groupNamePattern *regexp.Regexp
+	// the unit log sizes and lag are also published in
+	metricUnit string
+	// whether events are written to stdout as well as or instead of published, empty for neither
+	stdoutOutput string
+	// the broker serving the snapshot a simulation runs against, nil outside of one
+	simulated *sarama.MockBroker
+	// the id of the cluster added to every event as the field named, empty when it reports none
+	clusterId      string
+	clusterIdField string
+	// topics replicated from another cluster, nil when none are
+	mirror *mirror
+	// whether the principals with access to each topic are published every refresh
+	topicAcls bool
+	// whether offsets are fetched only for the topics a group consumes, and the topics each group
+	// consumes, by group, until the next refresh
+	consumedTopicsOnly bool
+	consumption        map[string]map[string]bool
+	// topics with more partitions are only summed up, 0 for no limit
+	maxPartitions    int
+	summarizedTopics map[string]bool
+	// the offset store consuming the offsets topic, nil unless enabled
+	offsetsTopic *offsetsTopic
+	// how many times a tick that published nothing because of errors is retried, and how long after
+	tickRetries    int
+	tickRetryDelay time.Duration
+	// ticks completed, and how many must be before fields derived across ticks are published
+	ticks       int
+	warmupTicks int
+	// the metricset every event is published under, for Metricbeat style ingest pipelines
+	metricsetName   string
+	metricsetModule string
+	// the address collection stats are served on through expvar, empty to serve none
+	expvarBind string
+	// what the last collection pass covered
+	scope *scope
+	// the collector every event is attributed to
+	collector string
+	batch     batchStats
 }
 
 // Creates beater
 func New() *Kafkabeat {
+	done := make(chan struct{})
 	return &Kafkabeat{
-		done: make(chan struct{}),
+		done:             done,
+		finished:         make(chan struct{}),
+		cutoff:           done,
+		finalTickTimeout: defaultFinalTickTimeout,
+		state:            newStateCache(defaultStateCacheSize),
+		metricsetName:    defaultMetricsetName,
+		metricsetModule:  defaultMetricsetModule,
+		metricUnit:       defaultMetricUnit,
+		clusterIdField:   defaultClusterIdField,
+		brokerSource:     zookeeperBrokers,
+		tickRetryDelay:   defaultTickRetryDelay,
+		concurrency:      1,
+		offsetResetGrace: defaultOffsetResetGrace,
+		maxConcurrency:   defaultMaxConcurrency,
+		summarizedTopics: make(map[string]bool),
+		scope:            newScope(),
+		consumption:      make(map[string]map[string]bool),
 	}
 }
 
 /// *** Beater interface methods ***///
 
 func (bt *Kafkabeat) Config(b *beat.Beat) error {
-        fmt.Println("Reading Config")
+	// stderr, as stdout_output may leave stdout to events
+	fmt.Fprintln(os.Stderr, "Reading Config")
 	// Load beater beatConfig
 	logp.Info("Configuring Kafkabeat...")
 	var err error
-	err = cfgfile.Read(&bt.beatConfig, "")
+	err = readConfig(&bt.beatConfig, "")
 	if err != nil {
 		return fmt.Errorf("Error reading config file: %v", err)
 	}
 
-	bt.zookeepers = bt.beatConfig.Kafkabeat.Zookeepers
-	if bt.zookeepers == nil || len(bt.zookeepers) == 0 {
-		return KafkabeatError{"Atleast one zookeeper must be defined"}
-	}
-	chroot := bt.beatConfig.Kafkabeat.Chroot
-	var kazooConfig *kazoo.Config
-	if chroot != "" {
-		defaultConfig := kazoo.NewConfig()
-		kazooConfig = &kazoo.Config{Chroot: chroot, Timeout: defaultConfig.Timeout, Logger: defaultConfig.Logger}
-	}
-	zClient,err = kazoo.NewKazoo(bt.zookeepers, kazooConfig)
-	if err != nil {
-		logp.Err("Unable to connect to Zookeeper")
-		return err
+	if *simulateSnapshot != "" {
+		recorded, err := loadSnapshot(*simulateSnapshot)
+		if err != nil {
+			return err
+		}
+		bt.simulated, err = recorded.serve()
+		if err != nil {
+			return err
+		}
+		recorded.stubZookeeper(bt.simulated)
+	} else {
+		bt.zookeepers = bt.beatConfig.Kafkabeat.Zookeepers
+		if bt.zookeepers == nil || len(bt.zookeepers) == 0 {
+			return KafkabeatError{"Atleast one zookeeper must be defined"}
+		}
+		chroot := bt.beatConfig.Kafkabeat.Chroot
+		var kazooConfig *kazoo.Config
+		if chroot != "" {
+			defaultConfig := kazoo.NewConfig()
+			kazooConfig = &kazoo.Config{Chroot: chroot, Timeout: defaultConfig.Timeout, Logger: defaultConfig.Logger}
+		}
+		zClient, err = kazoo.NewKazoo(bt.zookeepers, kazooConfig)
+		if err != nil {
+			logp.Err("Unable to connect to Zookeeper")
+			return err
+		}
 	}
-	bt.brokers,err = zClient.BrokerList()
+	bt.brokers, err = brokerList()
 	if err != nil{
 		logp.Err("Error identifying brokers from zookeeper")
 		return err
@@ -75,9 +286,130 @@ func (bt *Kafkabeat) Config(b *beat.Beat) error {
 		return KafkabeatError{"Unable to identify active brokers"}
 	}
 	logp.Info("Brokers: %v",bt.brokers)
-	client,err = sarama.NewClient(bt.brokers,sarama.NewConfig())
-	groups, _ := zClient.Consumergroups()
-	fmt.Println(groups)
+	saramaConfig := sarama.NewConfig()
+	if bt.beatConfig.Kafkabeat.KafkaVersion != "" {
+		saramaConfig.Version, err = sarama.ParseKafkaVersion(bt.beatConfig.Kafkabeat.KafkaVersion)
+		if err != nil {
+			return err
+		}
+	}
+	if err = applyMetadataConfig(bt.beatConfig.Kafkabeat.Metadata, saramaConfig); err != nil {
+		return err
+	}
+	bt.stableCluster = bt.beatConfig.Kafkabeat.StableCluster
+	if bt.stableCluster {
+		// no background refresh, the metadata being refreshed along with discovery
+		saramaConfig.Metadata.RefreshFrequency = 0
+	}
+	if err = applySASLConfig(bt.beatConfig.Kafkabeat.SASL, saramaConfig); err != nil {
+		return err
+	}
+	client, err = sarama.NewClient(bt.brokers, saramaConfig)
+	if err != nil {
+		logp.Err("Unable to connect to brokers %v", bt.brokers)
+		return err
+	}
+	bt.brokerSource, err = checkBrokerSource(bt.beatConfig.Kafkabeat.BrokerSource)
+	if err != nil {
+		return err
+	}
+	bt.brokers = bt.chooseBrokers(bt.brokers, metadataBrokerList())
+	if bt.beatConfig.Kafkabeat.ClusterIdField != "" {
+		bt.clusterIdField = bt.beatConfig.Kafkabeat.ClusterIdField
+	}
+	bt.clusterId, err = clusterId()
+	if err != nil {
+		// events are published without it rather than not at all
+		logp.Warn("Unable to read the cluster id: %v", err)
+	}
+	// a simulation touches no cluster but the snapshot's
+	if len(bt.beatConfig.Kafkabeat.Mirror.SourceBrokers) > 0 && bt.simulated == nil {
+		bt.mirror, err = newMirror(bt.beatConfig.Kafkabeat.Mirror, saramaConfig)
+		if err != nil {
+			logp.Err("Unable to connect to mirror source brokers %v", bt.beatConfig.Kafkabeat.Mirror.SourceBrokers)
+			return err
+		}
+	}
+	if bt.beatConfig.Kafkabeat.OffsetsTopic {
+		bt.offsetsTopic = newOffsetsTopic()
+		RegisterOffsetStore(offsetsTopicStore, bt.offsetsTopic)
+		// read in place of the coordinators unless the stores are configured
+		offsetStores = []string{offsetsTopicStore, zookeeperStore}
+	}
+	if bt.beatConfig.Kafkabeat.OffsetStores != nil {
+		if err = useOffsetStores(bt.beatConfig.Kafkabeat.OffsetStores); err != nil {
+			return err
+		}
+	}
+	if err = bt.usePipelines(bt.beatConfig.Kafkabeat.Pipelines); err != nil {
+		return err
+	}
+	if bt.beatConfig.Kafkabeat.OffsetResetGrace != "" {
+		bt.offsetResetGrace, err = time.ParseDuration(bt.beatConfig.Kafkabeat.OffsetResetGrace)
+		if err != nil {
+			return err
+		}
+	}
+	if bt.beatConfig.Kafkabeat.AbandonedAfter != "" {
+		bt.abandonedAfter, err = time.ParseDuration(bt.beatConfig.Kafkabeat.AbandonedAfter)
+		if err != nil {
+			return err
+		}
+	}
+	bt.suppressAbandoned = bt.beatConfig.Kafkabeat.SuppressAbandoned
+	if bt.beatConfig.Kafkabeat.GroupNamePattern != "" {
+		bt.groupNamePattern, err = compileGroupNamePattern(bt.beatConfig.Kafkabeat.GroupNamePattern)
+		if err != nil {
+			return err
+		}
+	}
+	bt.sampling, err = newSampling(bt.beatConfig.Kafkabeat.SamplePartitions)
+	if err != nil {
+		return err
+	}
+	if err = bt.useTopicSettings(bt.beatConfig.Kafkabeat.TopicDefaults, bt.beatConfig.Kafkabeat.TopicSettings); err != nil {
+		return err
+	}
+	bt.collector, err = collectorName(bt.beatConfig.Kafkabeat.CollectorName)
+	if err != nil {
+		return err
+	}
+	if bt.beatConfig.Kafkabeat.PushGateway.URL != "" && bt.simulated == nil {
+		bt.pushGateway, err = newPushGateway(bt.beatConfig.Kafkabeat.PushGateway, bt.collector)
+		if err != nil {
+			return err
+		}
+	}
+	bt.compactLagBelow = bt.beatConfig.Kafkabeat.CompactLagBelow
+	bt.lagEmaAlpha = bt.beatConfig.Kafkabeat.LagEmaAlpha
+	if bt.lagEmaAlpha < 0 || bt.lagEmaAlpha > 1 {
+		return fmt.Errorf("lag_ema_alpha %v must be between 0 and 1", bt.lagEmaAlpha)
+	}
+	bt.lagThreshold = bt.beatConfig.Kafkabeat.LagThreshold
+	bt.lagThresholds = bt.beatConfig.Kafkabeat.LagThresholds
+	bt.lagBudget = bt.beatConfig.Kafkabeat.LagBudget
+	bt.lagCap = bt.beatConfig.Kafkabeat.LagCap
+	bt.lagBudgets = bt.beatConfig.Kafkabeat.LagBudgets
+	bt.partitionBytes = bt.beatConfig.Kafkabeat.PartitionBytes
+	if bt.partitionBytes && !saramaConfig.Version.IsAtLeast(sarama.V1_0_0_0) {
+		logp.Warn("Kafka version %v predates describing log dirs, partition bytes are not reported", saramaConfig.Version)
+		bt.partitionBytes = false
+	}
+	bt.topicAge = bt.beatConfig.Kafkabeat.TopicAge
+	if bt.topicAge && !saramaConfig.Version.IsAtLeast(sarama.V0_10_0_0) {
+		logp.Warn("Kafka version %v predates message timestamps, the age of topics is not reported", saramaConfig.Version)
+		bt.topicAge = false
+	}
+	bt.offsetSpecs, err = newOffsetSpecs(bt.beatConfig.Kafkabeat.OffsetSpecs, saramaConfig.Version)
+	if err != nil {
+		return err
+	}
+	if bt.beatConfig.Kafkabeat.UseAdminClient {
+		if err = connectAdmin(); err != nil {
+			logp.Err("Unable to create the admin client")
+			return err
+		}
+	}
 	//topics := []string{"test"}
 	//consumer := zClient.Consumergroup("test-consumer-group").NewInstance()
 	//consumer.Register(topics)
@@ -90,117 +422,803 @@ func (bt *Kafkabeat) Config(b *beat.Beat) error {
 	if err != nil {
 		return err
 	}
+	bt.periodJitter, err = periodJitterFor(bt.beatConfig.Kafkabeat.PeriodJitter, bt.period)
+	if err != nil {
+		return err
+	}
+	if bt.beatConfig.Kafkabeat.RefreshPeriod == "" {
+		bt.beatConfig.Kafkabeat.RefreshPeriod = "1m"
+	}
+	bt.refreshPeriod, err = time.ParseDuration(bt.beatConfig.Kafkabeat.RefreshPeriod)
+	if err != nil {
+		return err
+	}
+	bt.state = newStateCache(bt.beatConfig.Kafkabeat.StateCacheSize)
+	if bt.beatConfig.Kafkabeat.MaxOffsetAge != "" {
+		bt.maxOffsetAge, err = time.ParseDuration(bt.beatConfig.Kafkabeat.MaxOffsetAge)
+		if err != nil {
+			return err
+		}
+	}
+	bt.suppressStaleLag = bt.beatConfig.Kafkabeat.SuppressStaleLag
+	bt.offsetBasis = bt.beatConfig.Kafkabeat.OffsetBasis
+	if bt.offsetBasis == "" {
+		bt.offsetBasis = highWatermark
+	}
+	bt.topicOffsetBasis = bt.beatConfig.Kafkabeat.TopicOffsetBasis
+	for _, basis := range append([]string{bt.offsetBasis}, mapValues(bt.topicOffsetBasis)...) {
+		if basis != highWatermark && basis != lastStable {
+			return fmt.Errorf("Unknown offset_basis %v, expected %v or %v", basis, highWatermark, lastStable)
+		}
+	}
+	if err = bt.checkTopicSettings(); err != nil {
+		return err
+	}
+	bt.suppressUnchanged = bt.beatConfig.Kafkabeat.SuppressUnchanged
+	bt.maxSuppressTicks = bt.beatConfig.Kafkabeat.MaxSuppressTicks
+	if bt.maxSuppressTicks <= 0 {
+		bt.maxSuppressTicks = defaultMaxSuppressTicks
+	}
+	bt.reportBatchStats = bt.beatConfig.Kafkabeat.PublishBatchStats
+	bt.warmupTicks = bt.beatConfig.Kafkabeat.WarmupTicks
+	bt.tickRetries = bt.beatConfig.Kafkabeat.TickRetries
+	if bt.beatConfig.Kafkabeat.Concurrency > 0 {
+		bt.concurrency = bt.beatConfig.Kafkabeat.Concurrency
+	}
+	bt.autoTune = bt.beatConfig.Kafkabeat.AutoTune
+	if bt.beatConfig.Kafkabeat.MaxConcurrency > 0 {
+		bt.maxConcurrency = bt.beatConfig.Kafkabeat.MaxConcurrency
+	}
+	bt.grouping = bt.beatConfig.Kafkabeat.Grouping
+	if bt.grouping == "" {
+		bt.grouping = perPartition
+	}
+	if bt.grouping != perPartition && bt.grouping != perTopic {
+		return fmt.Errorf("Unknown grouping %v, expected %v or %v", bt.grouping, perPartition, perTopic)
+	}
+	bt.timestampAlignment = bt.beatConfig.Kafkabeat.TimestampAlign
+	if bt.timestampAlignment == "" {
+		bt.timestampAlignment = exactAlignment
+	}
+	if bt.timestampAlignment != exactAlignment && bt.timestampAlignment != periodAlignment {
+		return fmt.Errorf("Unknown timestamp_alignment %v, expected %v or %v", bt.timestampAlignment, exactAlignment, periodAlignment)
+	}
+	if bt.beatConfig.Kafkabeat.MetricsetName != "" {
+		bt.metricsetName = bt.beatConfig.Kafkabeat.MetricsetName
+	}
+	if bt.beatConfig.Kafkabeat.MetricsetModule != "" {
+		bt.metricsetModule = bt.beatConfig.Kafkabeat.MetricsetModule
+	}
+	bt.metricUnit, err = metricUnitFor(bt.beatConfig.Kafkabeat.MetricUnit)
+	if err != nil {
+		return err
+	}
+	bt.stdoutOutput = bt.beatConfig.Kafkabeat.StdoutOutput
+	if err = checkStdoutOutput(bt.stdoutOutput); err != nil {
+		return err
+	}
+	if bt.simulated != nil {
+		// previewed rather than published
+		bt.stdoutOutput = stdoutOnly
+	}
+	bt.activeGroupsOnly = bt.beatConfig.Kafkabeat.ActiveGroupsOnly
+	bt.normalizeGroupNames = bt.beatConfig.Kafkabeat.NormalizeGroups
+	bt.maxGroupsPerTick = bt.beatConfig.Kafkabeat.MaxGroupsPerTick
+	bt.maxPartitions = bt.beatConfig.Kafkabeat.MaxPartitions
+	bt.minTopicSize = bt.beatConfig.Kafkabeat.MinTopicSize
+	bt.groupAggregates, err = newGroupAggregates(bt.beatConfig.Kafkabeat.AggregateGroups)
+	if err != nil {
+		return err
+	}
+	bt.topicIndexes, err = newTopicIndexes(bt.beatConfig.Kafkabeat.TopicIndexMap)
+	if err != nil {
+		return err
+	}
+	bt.suppressAggregated = bt.beatConfig.Kafkabeat.SuppressAggregate
+	bt.consumedTopicsOnly = bt.beatConfig.Kafkabeat.ConsumedTopics
+	bt.topicAcls = bt.beatConfig.Kafkabeat.TopicAcls
+	bt.expvarBind = bt.beatConfig.Kafkabeat.ExpvarBind
+	bt.topicPrefix = bt.beatConfig.Kafkabeat.TopicPrefix
+	bt.topicWatch = bt.beatConfig.Kafkabeat.TopicWatch
+	if bt.beatConfig.Kafkabeat.NewTopicGrace != "" {
+		bt.newTopicGrace, err = time.ParseDuration(bt.beatConfig.Kafkabeat.NewTopicGrace)
+		if err != nil {
+			return err
+		}
+	}
+	if bt.beatConfig.Kafkabeat.FinalTickTimeout != "" {
+		bt.finalTickTimeout, err = time.ParseDuration(bt.beatConfig.Kafkabeat.FinalTickTimeout)
+		if err != nil {
+			return err
+		}
+	}
+	if bt.beatConfig.Kafkabeat.PauseThreshold != "" {
+		bt.pauseThreshold, err = time.ParseDuration(bt.beatConfig.Kafkabeat.PauseThreshold)
+		if err != nil {
+			return err
+		}
+	}
+	collectionErrors.enabled = bt.beatConfig.Kafkabeat.EmitErrors
+	if bt.beatConfig.Kafkabeat.SkipLeaderless != nil {
+		skipLeaderless = *bt.beatConfig.Kafkabeat.SkipLeaderless
+	}
+	if bt.beatConfig.Kafkabeat.GroupStates != nil {
+		if err = useGroupStates(bt.beatConfig.Kafkabeat.GroupStates); err != nil {
+			return err
+		}
+	}
+	if bt.beatConfig.Kafkabeat.ErrorDedupeWindow != "" {
+		collectionErrors.window, err = time.ParseDuration(bt.beatConfig.Kafkabeat.ErrorDedupeWindow)
+		if err != nil {
+			return err
+		}
+	}
+	return bt.resolve()
+}
+
+// resolve the configured topics and groups, falling back to discovery when not set
+func (bt *Kafkabeat) resolve() error {
+	var err error
+	if len(bt.beatConfig.Kafkabeat.Watch) > 0 {
+		bt.watch, err = resolveWatch(bt.beatConfig.Kafkabeat.Watch)
+		if err != nil {
+			return err
+		}
+		bt.topics, bt.groups = watchedTopicsAndGroups(bt.watch)
+		bt.create_topic_docs = true
+		logp.Info("Watching %v partitions", len(bt.beatConfig.Kafkabeat.Watch))
+		return nil
+	}
 	bt.topics = bt.beatConfig.Kafkabeat.Topics
 	bt.create_topic_docs=true
 	if bt.topics == nil || len(bt.topics) == 0 {
 		bt.create_topic_docs = bt.topics == nil
+		bt.discoverTopics = true
 		bt.topics,err = client.Topics()
 	}
 	if err != nil {
 		return err
 	}
+	if bt.discoverTopics {
+		bt.topics = matchingTopics(bt.topics, bt.topicPrefix)
+		// the topics there at startup are monitored straight away, only those created later wait out the grace period
+		bt.topicsSeen = make(map[string]time.Time, len(bt.topics))
+		for _, topic := range bt.topics {
+			bt.topicsSeen[topic] = time.Time{}
+		}
+	}
 	logp.Info("Monitoring topics: %v",bt.topics)
+	// an unset list discovers the groups, an empty one monitors none and never asks for them
 	bt.groups = bt.beatConfig.Kafkabeat.Groups
-
-	if bt.groups == nil {
-		bt.groups,err = getGroups()
+	bt.discoverGroups = bt.groups == nil
+	if bt.discoverGroups {
+		bt.refreshGroups()
+	} else if len(bt.groups) == 0 {
+		logp.Info("No groups configured, only topics are monitored")
 	}
 	logp.Info("Monitoring groups %v",bt.groups)
-	return err
+	return nil
+}
+
+// refreshGroups re-discovers the groups to monitor. Failing to leaves the groups previously found in place,
+// so topics are monitored regardless, and discovery is retried on the next refresh.
+func (bt *Kafkabeat) refreshGroups() {
+	groups, duplicates, err := getGroups(bt.activeGroupsOnly)
+	if err != nil {
+		logp.Warn("Group discovery failed, retrying in %v: %v", bt.refreshPeriod, err)
+		if bt.groups == nil {
+			bt.groups = []string{}
+		}
+		return
+	}
+	bt.groups = bt.normalizeGroups(groups)
+	duplicateGroups = make(map[string]bool)
+	for _, group := range duplicates {
+		duplicateGroups[group] = true
+	}
+	if len(duplicates) > 0 {
+		logp.Warn("Groups %v are registered in Zookeeper and coordinated by the brokers, reporting the offsets in each apart", duplicates)
+	}
 }
 
+// reportDuplicateGroups publishes the discovered groups found in both Zookeeper and Kafka
+func (bt *Kafkabeat) reportDuplicateGroups(b *beat.Beat) {
+	if len(duplicateGroups) == 0 {
+		return
+	}
+	var duplicates []string
+	for group := range duplicateGroups {
+		duplicates = append(duplicates, group)
+	}
+	sort.Strings(duplicates)
+	bt.publish(b, []common.MapStr{{
+		"@timestamp":      common.Time(time.Now()),
+		"type":            "kafkabeat",
+		"duplicateGroups": duplicates,
+	}})
+}
 
-func getGroups() ([]string,error) {
-	group_list,err :=zClient.Consumergroups()
+// checkGroups warns about and publishes the configured groups neither Zookeeper nor the brokers know of,
+// which are left fetching no offsets once renamed or removed
+func (bt *Kafkabeat) checkGroups(b *beat.Beat) {
+	if bt.discoverGroups || len(bt.groups) == 0 {
+		return
+	}
+	known, _, err := getGroups(false)
+	if err != nil {
+		logp.Warn("Unable to check the configured groups exist: %v", err)
+		return
+	}
+	unknown := unknownGroups(bt.groups, known)
+	if len(unknown) == 0 {
+		return
+	}
+	logp.Warn("Configured groups %v are not known to Zookeeper or the brokers", unknown)
+	bt.publish(b, []common.MapStr{{
+		"@timestamp":    common.Time(time.Now()),
+		"type":          "kafkabeat",
+		"unknownGroups": unknown,
+	}})
+}
+
+// unknownGroups lists the configured groups missing from the known ones
+func unknownGroups(configured []string, known []string) []string {
+	found := make(map[string]bool)
+	for _, group := range known {
+		found[group] = true
+	}
+	var unknown []string
+	for _, group := range configured {
+		if !found[group] {
+			unknown = append(unknown, group)
+		}
+	}
+	return unknown
+}
+
+// getGroups merges the consumer groups registered in Zookeeper with those the brokers coordinate,
+// also returning those found in both. With activeOnly the brokers' groups without members are left out
+func getGroups(activeOnly bool) ([]string, []string, error) {
+	zookeeper, err := zookeeperGroups()
 	if err != nil {
 		logp.Err("Unable to retrieve groups")
-		return nil,err
+		return nil, nil, err
 	}
-	groups:=make([]string,len(group_list))
-	for i, group := range group_list {
-		groups[i]=group.Name
+	kafka, err := kafkaGroups()
+	if err != nil {
+		logp.Err("Unable to list groups from brokers")
+		return nil, nil, err
+	}
+	if activeOnly {
+		kafka = activeGroups(kafka)
+	}
+	return mergeGroups(zookeeper, kafka), commonGroups(zookeeper, kafka), nil
+}
+
+// kafkaGroups lists the consumer groups coordinated by each of the brokers
+func kafkaGroups() ([]string, error) {
+	var groups []string
+	for _, broker := range client.Brokers() {
+		err := broker.Open(client.Config())
+		if err != nil && err != sarama.ErrAlreadyConnected {
+			return nil, err
+		}
+		res, err := broker.ListGroups(&sarama.ListGroupsRequest{})
+		if err != nil {
+			return nil, err
+		}
+		if res.Err != sarama.ErrNoError {
+			return nil, res.Err
+		}
+		for group, protocolType := range res.Groups {
+			if protocolType == "consumer" {
+				groups = append(groups, group)
+			}
+		}
 	}
 	return groups,nil
 }
 
+// commonGroups returns the sorted groups found in both lists
+func commonGroups(first []string, second []string) []string {
+	found := make(map[string]bool)
+	for _, group := range first {
+		found[group] = true
+	}
+	var common []string
+	for _, group := range second {
+		if found[group] {
+			common = append(common, group)
+			found[group] = false
+		}
+	}
+	sort.Strings(common)
+	return common
+}
+
+// mergeGroups returns the sorted union of the group lists
+func mergeGroups(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	groups := []string{}
+	for _, list := range lists {
+		for _, group := range list {
+			if !seen[group] {
+				seen[group] = true
+				groups = append(groups, group)
+			}
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
 
 func (bt *Kafkabeat) Setup(b *beat.Beat) error {
 	return nil
 }
 
 func (bt *Kafkabeat) Run(b *beat.Beat) error {
+	defer close(bt.finished)
+	if *printResolved {
+		resolved, err := bt.resolvedJSON()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(resolved))
+		return nil
+	}
+	if bt.simulated != nil {
+		bt.simulate(b)
+		return nil
+	}
 	logp.Info("kafkabeat is running! Hit CTRL-C to stop it.")
-	ticker := time.NewTicker(bt.period)
+	if bt.expvarBind != "" {
+		if _, err := serveExpvar(bt.expvarBind); err != nil {
+			logp.Err("Unable to serve collection stats on %v: %v", bt.expvarBind, err)
+			return err
+		}
+	}
+	if bt.offsetsTopic != nil {
+		if err := bt.offsetsTopic.consume(bt.done); err != nil {
+			logp.Err("Unable to consume %s: %v", consumerOffsetsTopic, err)
+			return err
+		}
+	}
+	bt.checkGroups(b)
+	bt.reportDuplicateGroups(b)
+	bt.publishTopicAcls(b)
+	ticks, stopTicks := newTicker(bt.period, bt.periodJitter)
+	defer stopTicks()
+	bt.tickDue = time.Now().Add(bt.period)
+	refresh := time.NewTicker(bt.refreshPeriod)
+	defer refresh.Stop()
+	refreshes := refresh.C
+	var hangups chan os.Signal
+	if bt.stableCluster {
+		refreshes = nil
+		hangups = make(chan os.Signal, 1)
+		signal.Notify(hangups, syscall.SIGHUP)
+		defer signal.Stop(hangups)
+	}
+	var topicChanges <-chan []string
+	if bt.discoverTopics && bt.topicWatch {
+		topicChanges = bt.watchTopics()
+	}
 	for {
 		select {
 		case <-bt.done:
+			bt.finalTick(b)
 			return nil
-		case <-ticker.C:
-			for _, topic := range bt.topics {
-				pids,err := processTopic(topic)
-				if err == nil {
-					if bt.create_topic_docs {
-						publishTopicDocs(topic, pids,b)
-					}
-					events:=processGroups(bt.groups,topic, pids)
-					if events !=nil && len(events) > 0 {
-						b.Events.PublishEvents(events)
-					}
+		case <-refreshes:
+			bt.rediscover(b)
+		case <-hangups:
+			bt.refreshStable(b, "SIGHUP")
+		case topics := <-topicChanges:
+			bt.setTopics(topics)
+		case fired := <-ticks:
+			if bt.skipsTick(fired) {
+				continue
+			}
+			if bt.pauseThreshold > 0 {
+				bt.publish(b, []common.MapStr{bt.scheduleDriftEvent(time.Now())})
+			}
+			bt.tick(b)
+			bt.tickDone = time.Now()
+		}
+	}
+}
+
+// collect runs a collection pass over the monitored topics, cutting it short once the beat is stopped.
+// It returns the first error that left a topic unprocessed
+func (bt *Kafkabeat) collect(b *beat.Beat) error {
+	var failed error
+	bt.scope = newScope()
+	keepGroupDescriptions(true)
+	defer func() {
+		keepGroupDescriptions(false)
+		bt.publish(b, brokerLatencies.events())
+	}()
+	if bt.mirror != nil {
+		bt.publish(b, bt.mirror.lagEvents())
+	}
+	if bt.watch != nil {
+		bt.collectWatched(b)
+		return nil
+	}
+	groups := bt.groupsThisTick()
+	if event := bt.groupsCoveredEvent(groups); event != nil {
+		bt.publish(b, []common.MapStr{event})
+	}
+	// a topic's partition events are needed to fold into its single event even without topic documents
+	sizeTopics := bt.create_topic_docs || bt.grouping == perTopic
+	var leaders map[int32]int
+	var usage *diskUsage
+	if sizeTopics {
+		leaders = leadershipCounts(bt.topics)
+		if bt.partitionBytes {
+			usage = getDiskUsage()
+			bt.publish(b, usage.events())
+		}
+	}
+	for _, topic := range bt.topics {
+		if !bt.topicDue(topic) {
+			continue
+		}
+		pids, leaderless, err := processTopic(topic, bt.basisFor(topic), bt.topicSettingFor(topic).partitions, bt.concurrency, bt.cutoff)
+		if err == nil {
+			if len(leaderless) > 0 {
+				logp.Debug("kafkabeat", "Partitions %v of topic %s have no leader, skipping them", leaderless, topic)
+				bt.publish(b, leaderlessEvents(topic, leaderless))
+			}
+			bt.publish(b, bt.isrChangeEvents(topic, pids))
+			if bt.belowMinTopicSize(topic, pids) {
+				continue
+			}
+			if bt.topicAge {
+				bt.publish(b, []common.MapStr{topicAgeEvent(topic, pids, time.Now())})
+			}
+			var partitions []common.MapStr
+			if sizeTopics {
+				partitions = topicEvents(topic, pids)
+				markDiscovered(partitions, bt.discoverTopics)
+				bt.markPartitionCountChange(topic, partitions)
+				markHotBrokers(partitions, leaders)
+				usage.mark(partitions)
+			}
+			// read before sampling leaves partitions out
+			partitionLeaders := leadersOf(partitions)
+			var sample map[int32]bool
+			summarized := bt.grouping != perTopic && bt.summarizes(topic, len(pids))
+			if summarized {
+				// no partition is sampled, the summary being published once the lag is known
+				sample = map[int32]bool{}
+				partitions = nil
+			} else if topicSampling := bt.samplingFor(topic); bt.grouping != perTopic && topicSampling.applies(len(pids)) {
+				sample = topicSampling.sample(topic, pids)
+				bt.publish(b, []common.MapStr{topicSummaryEvent(topic, pids, sample)})
+				partitions = sampled(partitions, sample)
+			}
+			if bt.grouping != perTopic {
+				bt.markOffsetSpecs(partitions)
+			}
+			if bt.stopped() {
+				if bt.grouping != perTopic {
+					bt.publish(b, partitions)
 				}
+				return failed
+			}
+			topicGroups := groups
+			if bt.consumedTopicsOnly {
+				topicGroups = bt.groupsConsuming(groups, topic)
+			}
+			consumers := processGroups(topicGroups, topic, pids)
+			bt.scope.addTopic(len(pids))
+			bt.scope.addConsumers(topicGroups, consumers)
+			markHasConsumers(partitions, consumers)
+			if bt.grouping != perTopic {
+				bt.publish(b, partitions)
 			}
+			rollups := groupTopicEvents(topic, pids, consumers)
+			markLeaderLag(partitionLeaders, rollups, consumers)
+			if summarized {
+				summary := topicSummaryEvent(topic, pids, sample)
+				markSummaryLag(summary, rollups)
+				bt.publish(b, []common.MapStr{summary})
+			}
+			if sample != nil {
+				consumers = sampled(consumers, sample)
+			}
+			bt.markCommitRate(consumers)
+			bt.markOwnerChanges(consumers)
+			bt.markLagThresholds(consumers)
+			bt.markLagBudgets(consumers)
+			// after the alerts, which stale offsets may leave out
+			bt.markStale(consumers)
+			bt.markOffsetResets(consumers)
+			bt.markAbandoned(consumers)
+			bt.markLagEma(consumers)
+			bt.capLag(consumers)
+			if bt.grouping == perTopic {
+				event := perTopicEvent(topic, partitions, consumers)
+				markHasConsumers([]common.MapStr{event}, consumers)
+				markDiscovered([]common.MapStr{event}, bt.discoverTopics)
+				bt.publish(b, []common.MapStr{event})
+			} else {
+				bt.publish(b, bt.compactConsumers(bt.unaggregated(consumers), rollups))
+			}
+			rollups = bt.aggregateGroups(topic, rollups)
+			bt.markLagBudgets(rollups)
+			bt.markLagTrend(rollups)
+			bt.markCaughtUp(rollups)
+			bt.capLag(rollups)
+			bt.publish(b, rollups)
+		} else if failed == nil {
+			failed = err
 		}
+		if bt.stopped() {
+			return failed
+		}
+	}
+	return failed
+}
+
+func (bt *Kafkabeat) stopped() bool {
+	select {
+	case <-bt.cutoff:
+		return true
+	default:
+		return false
+	}
+}
+
+// refresh re-reads the parts of the cluster topology that change while running
+func (bt *Kafkabeat) refresh(b *beat.Beat) {
+	if err := bt.refreshBrokers(b); err != nil {
+		logp.Err("Unable to refresh brokers: %v", err)
+	}
+	if bt.discoverTopics && !bt.topicWatch {
+		bt.refreshTopics()
+	}
+	if bt.discoverGroups {
+		bt.refreshGroups()
+	}
+	bt.consumption = make(map[string]map[string]bool)
+}
+
+// refreshBrokers re-reads the broker list from Zookeeper, re-seeding the client when the set has changed
+// and publishing the brokers that joined or left
+func (bt *Kafkabeat) refreshBrokers(b *beat.Beat) error {
+	brokers, err := brokerList()
+	if err != nil {
+		return err
+	}
+	brokers = bt.chooseBrokers(brokers, metadataBrokerList())
+	if len(brokers) == 0 {
+		return KafkabeatError{"Unable to identify active brokers"}
+	}
+	added, removed := diffBrokers(bt.brokers, brokers)
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
 	}
+	logp.Info("Brokers changed, added: %v removed: %v", added, removed)
+	ids := brokerIds()
+	err = client.RefreshBrokers(brokers)
+	if err != nil {
+		return err
+	}
+	collectionStats.Add("reconnects", 1)
+	bt.brokers = brokers
+	err = client.RefreshMetadata()
+	for addr, id := range brokerIds() {
+		ids[addr] = id
+	}
+	bt.publish(b, append(membershipEvents("broker_added", added, ids), membershipEvents("broker_removed", removed, ids)...))
+	return err
 }
 
-func processTopic(topic string) (map[int32]int64,error){
+// brokerIds maps the address of each broker the client knows to its id
+func brokerIds() map[string]int32 {
+	ids := make(map[string]int32)
+	for _, broker := range client.Brokers() {
+		ids[broker.Addr()] = broker.ID()
+	}
+	return ids
+}
+
+// membershipEvents builds an event of the type for each of the brokers, with its id when known
+func membershipEvents(eventType string, brokers []string, ids map[string]int32) []common.MapStr {
+	events := make([]common.MapStr, 0, len(brokers))
+	for _, broker := range brokers {
+		event := common.MapStr{
+			"@timestamp": common.Time(time.Now()),
+			"type":       eventType,
+			"broker":     broker,
+		}
+		if id, ok := ids[broker]; ok {
+			event["brokerId"] = id
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// diffBrokers returns the brokers only present in current and those only present in previous
+func diffBrokers(previous []string, current []string) ([]string, []string) {
+	var added, removed []string
+	known := make(map[string]bool)
+	for _, broker := range previous {
+		known[broker] = true
+	}
+	for _, broker := range current {
+		if !known[broker] {
+			added = append(added, broker)
+		}
+		delete(known, broker)
+	}
+	for _, broker := range previous {
+		if known[broker] {
+			removed = append(removed, broker)
+		}
+	}
+	return added, removed
+}
+
+// resolvedJSON renders what Config resolved the monitored brokers, topics and groups to
+func (bt *Kafkabeat) resolvedJSON() ([]byte, error) {
+	return json.MarshalIndent(struct {
+		Brokers []string `json:"brokers"`
+		Topics  []string `json:"topics"`
+		Groups  []string `json:"groups"`
+	}{bt.brokers, bt.topics, bt.groups}, "", "  ")
+}
+
+// processTopic sizes the partitions of the topic, or of those of them pinned
+func processTopic(topic string, basis string, pinned []int32, concurrency int, done <-chan struct{}) (map[int32]int64, []int32, error) {
 	pids, err := client.Partitions(topic)
 	if err != nil {
 		logp.Err("Unable to retrieve paritions for topic %v",topic)
-		return nil,err
+		collectionErrors.record("partitions", common.MapStr{"topic": topic}, err)
+		return nil, nil, err
+	}
+	if len(pinned) > 0 {
+		var kept []int32
+		for _, pid := range pids {
+			if containsPartition(pinned, pid) {
+				kept = append(kept, pid)
+			}
+		}
+		pids = kept
 	}
 	logp.Info("Partitions retrieved for topic %v",topic)
-	return getPartitionSizes(topic, pids), nil
+	pids, leaderless := leaderlessPartitions(topic, pids)
+	return getPartitionSizes(topic, pids, basis, concurrency, done), leaderless, nil
 }
 
-func publishTopicDocs(topic string,pids map[int32]int64,b *beat.Beat){
-	events := make([]common.MapStr, len(pids))
-	counter := 0
-	for pid, size := range pids {
-		events[counter] = common.MapStr{
-			"@timestamp": common.Time(time.Now()),
-			"type": "topic",
-			"partition": pid,
-			"topic":topic,
-			"size": size,
+// topicEvents builds an event per partition with its size and replica placement, in ascending partition order
+func topicEvents(topic string, pids map[int32]int64) []common.MapStr {
+	events := make([]common.MapStr, 0, len(pids))
+	for _, pid := range sortedPartitions(pids) {
+		size := pids[pid]
+		event := common.MapStr{
+			"@timestamp":         common.Time(time.Now()),
+			"type":               "topic",
+			"partition":          pid,
+			"topic":              topic,
+			"size":               size,
+			"replicaAssignment":  []int32{},
+			"preferredLeader":    unknownBroker,
+			"leader":             unknownBroker,
+			"leaderNotPreferred": false,
 		}
-		counter++
-	}
-	if len(events) > 0 {
-		b.Events.PublishEvents(events)
-		logp.Info("%v Events sent", len(events))
+		replicas, err := client.Replicas(topic, pid)
+		if err != nil {
+			logp.Err("Unable to identify replicas for partition %v and topic %s", pid, topic)
+			collectionErrors.record("replicas", common.MapStr{"topic": topic, "partition": pid}, err)
+		} else if len(replicas) > 0 {
+			// the first replica is the one a preferred leader election hands leadership back to
+			event.Update(common.MapStr{"replicaAssignment": replicas, "preferredLeader": replicas[0]})
+			leader, err := client.Leader(topic, pid)
+			if err != nil {
+				logp.Err("Unable to identify leader for partition %v and topic %s", pid, topic)
+				collectionErrors.record("leader", common.MapStr{"topic": topic, "partition": pid}, err)
+			} else {
+				event.Update(common.MapStr{"leader": leader.ID(), "leaderNotPreferred": leader.ID() != replicas[0]})
+			}
+		}
+		events = append(events, event)
 	}
+	return events
 }
 
+// markPartitionCountChange flags the topic events when the topic's partition count differs from the
+// one last seen. Topics seen for the first time have nothing to compare against and are not flagged
+func (bt *Kafkabeat) markPartitionCountChange(topic string, events []common.MapStr) {
+	pids, err := client.Partitions(topic)
+	if err != nil {
+		logp.Err("Unable to count partitions for topic %s", topic)
+		collectionErrors.record("partitions", common.MapStr{"topic": topic}, err)
+		return
+	}
+	key := stateKey("partitionCount", topic)
+	previous, seen := bt.state.Get(key)
+	bt.state.Put(key, len(pids))
+	if !seen || previous.(int) == len(pids) {
+		return
+	}
+	logp.Info("Topic %s partition count changed from %v to %v", topic, previous, len(pids))
+	for _, event := range events {
+		event.Update(common.MapStr{"partitionCountChanged": true, "previousPartitionCount": previous})
+	}
+}
 
+// processGroups builds the consumer events of the groups for the topic. Every group's lag is taken against
+// the same partition sizes, read once for the topic each tick, so the groups are compared on one snapshot
+// and no group sizes the partitions again
 func processGroups(groups []string, topic string,pids map[int32]int64) ([]common.MapStr){
 	var events []common.MapStr
-	for _,group := range groups {
-		pid_offsets,err := getConsumerOffsets(group, topic, pids)
+	// sorted so that the events of a tick come out in the same order every tick
+	sortedGroups := append([]string(nil), groups...)
+	sort.Strings(sortedGroups)
+	for _, group := range sortedGroups {
+		offsetSets, err := committedOffsetSets(group, topic, pids)
 		if err == nil {
-			for pid,offset := range pid_offsets {
-				event:=common.MapStr{
-					"@timestamp": common.Time(time.Now()),
-					"type": "consumer",
-					"partition": pid,
-					"topic":topic,
-					"group": group,
-					"offset": offset,
+			coordinator := unknownBroker
+			if broker, err := coordinatorFor(group); err == nil {
+				coordinator = broker.ID()
+			}
+			owners, assignor, state, err := getPartitionOwners(group, topic)
+			if err != nil {
+				logp.Debug("kafkabeat", "Unable to describe members of group %s: %v", group, err)
+				collectionErrors.record("describeGroups", common.MapStr{"topic": topic, "group": group}, err)
+			}
+			excluded := !groupStateIncluded(state)
+			if excluded {
+				logp.Debug("kafkabeat", "Leaving out the offsets group %s committed to Kafka as it is %s", group, state)
+			}
+			for _, pid_offsets := range offsetSets {
+				pids_committed := make([]int32, 0, len(pid_offsets))
+				for pid := range pid_offsets {
+					pids_committed = append(pids_committed, pid)
 				}
-				size,ok := pids[pid]
-				if ok {
-					event.Update(common.MapStr{"lag":size-offset,})
+				sortPartitions(pids_committed)
+				for _, pid := range pids_committed {
+					committed := pid_offsets[pid]
+					// the group's state in Kafka says nothing of the offsets it keeps in Zookeeper
+					if excluded && committed.store != zookeeperStore {
+						continue
+					}
+					offset := committed.offset
+					event := common.MapStr{
+						"@timestamp":   common.Time(time.Now()),
+						"type":         "consumer",
+						"partition":    pid,
+						"topic":        topic,
+						"group":        group,
+						"offset":       offset,
+						"offsetStore":  committed.store,
+						"coordinator":  unknownBroker,
+						"lag":          unknownOffset,
+						"logEndOffset": unknownOffset,
+					}
+					// only Kafka's offsets are held by the coordinator
+					if committed.store == kafkaStore {
+						event["coordinator"] = coordinator
+					}
+					size, ok := pids[pid]
+					if ok {
+						event.Update(common.MapStr{"lag": size - offset, "logEndOffset": size})
+					}
+					if owner, ok := owners[pid]; ok {
+						instanceId := ""
+						if owner.GroupInstanceId != nil {
+							instanceId = *owner.GroupInstanceId
+						}
+						event.Update(common.MapStr{"memberId": owner.MemberId, "instanceId": instanceId})
+					}
+					if assignor != "" {
+						event["assignor"] = assignor
+					}
+					events = append(events, event)
 				}
-				events=append(events,event)
 			}
 		} else {
 			logp.Debug("kafkabeat","No offsets for group %s on topic %s", group, topic)
@@ -209,44 +1227,126 @@ func processGroups(groups []string, topic string,pids map[int32]int64) ([]common
 	return events
 }
 
+// unknownOffset and unknownBroker stand in for the offsets and broker ids that couldn't be read, so that
+// every event of a type has the same fields whatever failed while it was collected
+const (
+	unknownOffset int64 = -1
+	unknownBroker int32 = -1
+)
+
+// lagOf returns the lag of a consumer event, false when it isn't known
+func lagOf(event common.MapStr) (int64, bool) {
+	lag, ok := event["lag"].(int64)
+	return lag, ok && event["logEndOffset"] != unknownOffset
+}
+
+// sortedPartitions returns the partitions ascending
+func sortedPartitions(pids map[int32]int64) []int32 {
+	sorted := make([]int32, 0, len(pids))
+	for pid := range pids {
+		sorted = append(sorted, pid)
+	}
+	sortPartitions(sorted)
+	return sorted
+}
+
+func sortPartitions(pids []int32) {
+	ints := make([]int, len(pids))
+	for i, pid := range pids {
+		ints[i] = int(pid)
+	}
+	sort.Ints(ints)
+	for i, pid := range ints {
+		pids[i] = int32(pid)
+	}
+}
 
-func getPartitionSizes(topic string, pids []int32) (map[int32]int64){
+// getPartitionSizes returns the size of each partition at the offset basis, sizing up to concurrency partitions at once,
+// or those sized so far once done is closed
+func getPartitionSizes(topic string, pids []int32, basis string, concurrency int, done <-chan struct{}) map[int32]int64 {
+	if concurrency < 1 {
+		concurrency = 1
+	}
 	pId_sizes := make(map[int32]int64)
+	var lock sync.Mutex
+	var workers sync.WaitGroup
+	work := make(chan int32)
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for pid := range work {
+				logp.Debug("kafkabeat", "Processing partition %v", pid)
+				pid_size, err := getPartitionSize(topic, pid, basis)
+				if err != nil {
+					logp.Err("Unable to identify size for partition %v and topic %s", pid, topic)
+					collectionErrors.record("getOffset", common.MapStr{"topic": topic, "partition": pid}, err)
+					continue
+				}
+				logp.Debug("kafkabeat", "Current log size is %v for partition %v", strconv.FormatInt(pid_size, 10), pid)
+				lock.Lock()
+				pId_sizes[pid] = pid_size
+				lock.Unlock()
+			}
+		}()
+	}
+	stopped := false
 	for _, pid := range pids {
-		logp.Debug("kafkabeat","Processing partition %v", pid)
-		pid_size,err:=client.GetOffset(topic, pid,sarama.OffsetNewest)
-		if err != nil {
-			logp.Err("Unable to identify size for partition %s and topic %s", pid,topic)
-		} else {
-			logp.Debug("kafkabeat","Current log size is %v for partition %v", strconv.FormatInt(pid_size,10), pid)
-			pId_sizes[pid]=pid_size
+		select {
+		case <-done:
+			stopped = true
+		case work <- pid:
 		}
-
+		if stopped {
+			break
+		}
+	}
+	close(work)
+	workers.Wait()
+	if stopped {
+		logp.Info("Stopped sizing topic %v after %v of %v partitions", topic, len(pId_sizes), len(pids))
 	}
 	return pId_sizes
 }
 
-
-func getConsumerOffsets(group string, topic string, pids map[int32]int64) (map[int32]int64,error) {
-	broker,err := client.Coordinator(group)
+func getConsumerOffsets(group string, topic string, pids []int32) (map[int32]int64, error) {
+	broker, err := coordinatorFor(group)
 	offsets := make(map[int32]int64)
 	if err != nil {
 		logp.Err("Unable to identify group coordinator for group %v",group)
+		collectionErrors.record("coordinator", common.MapStr{"topic": topic, "group": group}, err)
 	} else {
-		request:=sarama.OffsetFetchRequest{ConsumerGroup:group,Version:0}
-		for pid, size := range pids {
-			if size > 0 {
+		var res *sarama.OffsetFetchResponse
+		for {
+			request := sarama.OffsetFetchRequest{ConsumerGroup: group, Version: offsetFetchVersion()}
+			for _, pid := range pids {
 				request.AddPartition(topic, pid)
 			}
+			start := time.Now()
+			res, err = broker.FetchOffset(&request)
+			brokerLatencies.since(broker.ID(), start)
+			// brokers older than the version requested are asked again with the next lower one
+			if !rejectedVersion(res, err) {
+				break
+			}
+			if !lowerOffsetFetchVersion(request.Version) {
+				err = sarama.ErrUnsupportedVersion
+				break
+			}
 		}
-		res,err := broker.FetchOffset(&request)
 		if err != nil {
 			logp.Err("Issue fetching offsets coordinator for topic %v",topic)
 			logp.Err("%v",err)
+			collectionErrors.record("fetchOffset", common.MapStr{"topic": topic, "group": group}, err)
+			invalidateCoordinator(group)
 		}
 		if res != nil {
-			for pid,_  := range pids {
+			brokerThrottles.record(res.ThrottleTimeMs)
+			for _, pid := range pids {
 				offset := res.GetBlock(topic, pid)
+				if offset != nil && movedCoordinator(offset.Err) {
+					invalidateCoordinator(group)
+				}
 				if offset != nil && offset.Offset > -1{
 					offsets[pid]=offset.Offset
 				}
@@ -256,14 +1356,52 @@ func getConsumerOffsets(group string, topic string, pids map[int32]int64) (map[i
 	return offsets,err
 }
 
-
+// getPartitionOwners maps each partition of the topic to the group member it is currently assigned to, along with
+// the assignor the group's members agreed on e.g. range or cooperative-sticky, empty while the group rebalances,
+// and the state the group is in e.g. Stable or PreparingRebalance
+func getPartitionOwners(group string, topic string) (map[int32]*sarama.GroupMemberDescription, string, string, error) {
+	description, err := describeGroup(group)
+	if err != nil {
+		return nil, "", "", err
+	}
+	owners := make(map[int32]*sarama.GroupMemberDescription)
+	if description.ProtocolType != "consumer" {
+		return owners, "", description.State, nil
+	}
+	for _, member := range description.Members {
+		assignment, err := member.GetMemberAssignment()
+		if err != nil {
+			return nil, "", "", err
+		}
+		if assignment == nil {
+			continue
+		}
+		for _, pid := range assignment.Topics[topic] {
+			owners[pid] = member
+		}
+	}
+	return owners, description.Protocol, description.State, nil
+}
 
 func (bt *Kafkabeat) Cleanup(b *beat.Beat) error {
+	if bt.mirror != nil {
+		bt.mirror.source.Close()
+	}
+	if bt.offsetsTopic != nil {
+		bt.offsetsTopic.Close()
+	}
 	return client.Close()
 }
 
+// Stop has Run make its final pass and waits for Run to return, as libbeat calls Cleanup as soon as Stop
+// returns. The wait is bounded by the final pass's timeout
 func (bt *Kafkabeat) Stop() {
 	close(bt.done)
+	select {
+	case <-bt.finished:
+	case <-time.After(bt.finalTickTimeout + finalTickGrace):
+		logp.Warn("Stopping without waiting any longer for the final collection pass")
+	}
 }
 
 func (err KafkabeatError) Error() string {
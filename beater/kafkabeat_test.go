@@ -0,0 +1,736 @@
+package beater
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/publisher"
+	"github.com/gingerwizard/kafkabeat/config"
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+// newTestBroker starts a mock broker and a metadata response marking it leader of
+// every partition of the given topics
+func newTestBroker(t *testing.T, topics map[string]int32) (*sarama.MockBroker, *sarama.MockMetadataResponse) {
+	broker := sarama.NewMockBroker(t, 1)
+	metadata := sarama.NewMockMetadataResponse(t).SetBroker(broker.Addr(), broker.BrokerID())
+	for topic, partitions := range topics {
+		for pid := int32(0); pid < partitions; pid++ {
+			metadata.SetLeader(topic, pid, broker.BrokerID())
+		}
+	}
+	return broker, metadata
+}
+
+// connectTestClient points the package client at the mock broker
+func connectTestClient(t *testing.T, broker *sarama.MockBroker, conf *sarama.Config) {
+	if conf == nil {
+		conf = sarama.NewConfig()
+	}
+	var err error
+	client, err = sarama.NewClient([]string{broker.Addr()}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stubZookeeperOffsets serves the Zookeeper committed offsets of groups from a map until the returned
+// restore is called
+func stubZookeeperOffsets(offsets map[string]map[string]map[int32]int64) func() {
+	original := zookeeperOffset
+	zookeeperOffset = func(group string, topic string, pid int32) (int64, error) {
+		if offset, ok := offsets[group][topic][pid]; ok {
+			return offset, nil
+		}
+		return -1, nil
+	}
+	return func() { zookeeperOffset = original }
+}
+
+// encodeAssignment builds the wire format of a consumer group member assignment
+func encodeAssignment(topics map[string][]int32) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, int16(0))
+	binary.Write(buf, binary.BigEndian, int32(len(topics)))
+	for topic, pids := range topics {
+		binary.Write(buf, binary.BigEndian, int16(len(topic)))
+		buf.WriteString(topic)
+		binary.Write(buf, binary.BigEndian, int32(len(pids)))
+		binary.Write(buf, binary.BigEndian, pids)
+	}
+	binary.Write(buf, binary.BigEndian, int32(0))
+	return buf.Bytes()
+}
+
+// stubConfig has Config read the beat config given, with the brokers registered in Zookeeper being the
+// mock broker, until the returned restore is called
+func stubConfig(conf config.KafkabeatConfig, broker *sarama.MockBroker) func() {
+	originalRead, originalBrokers := readConfig, brokerList
+	readConfig = func(out interface{}, path string) error {
+		*out.(**config.Config) = &config.Config{Kafkabeat: conf}
+		return nil
+	}
+	brokerList = func() ([]string, error) { return []string{broker.Addr()}, nil }
+	return func() { readConfig, brokerList = originalRead, originalBrokers }
+}
+
+func TestResolveFlag(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1, "payments": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{"MetadataRequest": metadata})
+	defer stubConfig(config.KafkabeatConfig{Zookeepers: []string{"localhost:2181"}, Groups: []string{"billing"}}, broker)()
+	*printResolved = true
+	defer func() { *printResolved = false }()
+
+	bt := New()
+	b := &beat.Beat{Events: &capturePublisher{}}
+	lines := captureStdout(t, func() {
+		if err := bt.Config(b); err != nil {
+			t.Fatal(err)
+		}
+		if err := bt.Run(b); err != nil {
+			t.Fatal(err)
+		}
+	})
+	defer client.Close()
+	var resolved map[string][]string
+	if err := json.Unmarshal([]byte(strings.Join(lines, "\n")), &resolved); err != nil {
+		t.Fatalf("expected stdout to be the resolved JSON alone, got %q: %v", lines, err)
+	}
+	sort.Strings(resolved["topics"])
+	expected := map[string][]string{
+		"brokers": {broker.Addr()},
+		"topics":  {"orders", "payments"},
+		"groups":  {"billing"},
+	}
+	if !reflect.DeepEqual(resolved, expected) {
+		t.Errorf("expected %v, got %v", expected, resolved)
+	}
+}
+
+func TestProcessGroupsInstanceId(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2})
+	defer broker.Close()
+	instanceId := "orders-consumer-0"
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":        metadata,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 5, "", sarama.ErrNoError).
+			SetOffset("billing", "orders", 1, 15, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockWrapper(&sarama.DescribeGroupsResponse{
+			Version: 4,
+			Groups: []*sarama.GroupDescription{{
+				Version:      4,
+				GroupId:      "billing",
+				State:        "Stable",
+				ProtocolType: "consumer",
+				Members: map[string]*sarama.GroupMemberDescription{
+					"static": {
+						Version:          4,
+						MemberId:         "static",
+						GroupInstanceId:  &instanceId,
+						MemberAssignment: encodeAssignment(map[string][]int32{"orders": {0}}),
+					},
+					"dynamic": {
+						Version:          4,
+						MemberId:         "dynamic",
+						MemberAssignment: encodeAssignment(map[string][]int32{"orders": {1}}),
+					},
+				},
+			}},
+		}),
+	})
+	conf := sarama.NewConfig()
+	conf.Version = sarama.V2_4_0_0
+	conf.ApiVersionsRequest = false
+	connectTestClient(t, broker, conf)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	events := processGroups([]string{"billing"}, "orders", map[int32]int64{0: 10, 1: 20})
+	if len(events) != 2 {
+		t.Fatalf("expected 2 consumer events, got %v", events)
+	}
+	expected := map[int32]string{0: instanceId, 1: ""}
+	for _, event := range events {
+		pid := event["partition"].(int32)
+		if event["instanceId"] != expected[pid] {
+			t.Errorf("expected instanceId %q for partition %v, got %v", expected[pid], pid, event["instanceId"])
+		}
+	}
+}
+
+func TestGroupDescribedOncePerPass(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1, "payments": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":        metadata,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 5, "", sarama.ErrNoError).
+			SetOffset("billing", "payments", 0, 3, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockWrapper(&sarama.DescribeGroupsResponse{
+			Groups: []*sarama.GroupDescription{{
+				GroupId:      "billing",
+				State:        "Stable",
+				ProtocolType: "consumer",
+				Protocol:     "range",
+				Members: map[string]*sarama.GroupMemberDescription{
+					"member": {
+						MemberId:         "member",
+						MemberAssignment: encodeAssignment(map[string][]int32{"orders": {0}, "payments": {0}}),
+					},
+				},
+			}},
+		}),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	described := func() int {
+		count := 0
+		for _, exchange := range broker.History() {
+			if _, ok := exchange.Request.(*sarama.DescribeGroupsRequest); ok {
+				count++
+			}
+		}
+		return count
+	}
+	keepGroupDescriptions(true)
+	for _, topic := range []string{"orders", "payments"} {
+		events := processGroups([]string{"billing"}, topic, map[int32]int64{0: 10})
+		if len(events) != 1 || events[0]["memberId"] != "member" {
+			t.Errorf("expected the owner of the %v partition from the group's description, got %v", topic, events)
+		}
+	}
+	keepGroupDescriptions(false)
+	if count := described(); count != 1 {
+		t.Errorf("expected the group to be described once for both its topics, got %v", count)
+	}
+	processGroups([]string{"billing"}, "orders", map[int32]int64{0: 10})
+	if count := described(); count != 2 {
+		t.Errorf("expected the group to be described again the next pass, got %v", count)
+	}
+}
+
+func TestProcessGroupsLogEndOffset(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 4, "", sarama.ErrNoError).
+			SetOffset("billing", "orders", 1, 15, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	sizes := map[int32]int64{0: 10, 1: 20}
+	events := processGroups([]string{"billing"}, "orders", sizes)
+	if len(events) != 2 {
+		t.Fatalf("expected an event per partition, got %v", events)
+	}
+	for _, event := range events {
+		size := sizes[event["partition"].(int32)]
+		if event["logEndOffset"] != size {
+			t.Errorf("expected log end offset %v, got %v", size, event)
+		}
+		if event["lag"] != event["logEndOffset"].(int64)-event["offset"].(int64) {
+			t.Errorf("expected the lag to be reconstructable from the offsets, got %v", event)
+		}
+	}
+}
+
+func TestEmptyGroupsSkipsDiscovery(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	discovered := false
+	restore := zookeeperGroups
+	zookeeperGroups = func() ([]string, error) {
+		discovered = true
+		return []string{"billing"}, nil
+	}
+	defer func() { zookeeperGroups = restore }()
+
+	bt := New()
+	bt.offsetBasis = highWatermark
+	bt.beatConfig = &config.Config{Kafkabeat: config.KafkabeatConfig{Topics: []string{"orders"}, Groups: []string{}}}
+	if err := bt.resolve(); err != nil {
+		t.Fatal(err)
+	}
+	events := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: events})
+
+	if discovered {
+		t.Errorf("expected no group discovery for an empty groups list")
+	}
+	for _, exchange := range broker.History() {
+		if _, ok := exchange.Request.(*sarama.ListGroupsRequest); ok {
+			t.Errorf("expected the brokers not to be asked for their groups")
+		}
+	}
+	if consumers := events.ofType("consumer"); len(consumers) != 0 {
+		t.Errorf("expected no consumer events, got %v", consumers)
+	}
+	if topics := events.ofType("topic"); len(topics) != 1 {
+		t.Errorf("expected the topic to still be monitored, got %v", topics)
+	}
+}
+
+func TestCheckGroupsUnknown(t *testing.T) {
+	broker, metadata := newTestBroker(t, nil)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":   metadata,
+		"ListGroupsRequest": sarama.NewMockListGroupsResponse(t).AddGroup("payments", "consumer"),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	restore := zookeeperGroups
+	zookeeperGroups = func() ([]string, error) { return []string{"billing"}, nil }
+	defer func() { zookeeperGroups = restore }()
+
+	bt := New()
+	bt.groups = []string{"billing", "payments", "renamed"}
+	events := &capturePublisher{}
+	bt.checkGroups(&beat.Beat{Events: events})
+
+	health := events.ofType("kafkabeat")
+	if len(health) != 1 {
+		t.Fatalf("expected an event reporting the unknown groups, got %v", events.events)
+	}
+	if unknown := health[0]["unknownGroups"]; !reflect.DeepEqual(unknown, []string{"renamed"}) {
+		t.Errorf("expected only the renamed group to be unknown, got %v", unknown)
+	}
+}
+
+func TestRefreshBrokersJoin(t *testing.T) {
+	first, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer first.Close()
+	first.SetHandlerByMap(map[string]sarama.MockResponse{"MetadataRequest": metadata})
+	connectTestClient(t, first, nil)
+	defer client.Close()
+	if len(client.Brokers()) != 1 {
+		t.Fatalf("expected 1 broker before the join, got %v", client.Brokers())
+	}
+
+	second := sarama.NewMockBroker(t, 2)
+	defer second.Close()
+	joined := sarama.NewMockMetadataResponse(t).
+		SetBroker(first.Addr(), first.BrokerID()).
+		SetBroker(second.Addr(), second.BrokerID()).
+		SetLeader("orders", 0, first.BrokerID())
+	first.SetHandlerByMap(map[string]sarama.MockResponse{"MetadataRequest": joined})
+	second.SetHandlerByMap(map[string]sarama.MockResponse{"MetadataRequest": joined})
+
+	defer func(original func() ([]string, error)) { brokerList = original }(brokerList)
+	brokerList = func() ([]string, error) {
+		return []string{first.Addr(), second.Addr()}, nil
+	}
+
+	bt := New()
+	bt.brokers = []string{first.Addr()}
+	published := &capturePublisher{}
+	if err := bt.refreshBrokers(&beat.Beat{Events: published}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bt.brokers, []string{first.Addr(), second.Addr()}) {
+		t.Errorf("expected both brokers to be tracked, got %v", bt.brokers)
+	}
+	if added := published.ofType("broker_added"); len(added) != 1 || added[0]["broker"] != second.Addr() || added[0]["brokerId"] != int32(2) {
+		t.Errorf("expected an event for the broker that joined, got %v", added)
+	}
+	if len(client.Brokers()) != 2 {
+		t.Errorf("expected the client to know 2 brokers after the join, got %v", client.Brokers())
+	}
+}
+
+// newMetadataWrapper serves a fixed metadata response, versioned for the default client config
+func newMetadataWrapper(build func(*sarama.MetadataResponse)) sarama.MockResponse {
+	metadata := &sarama.MetadataResponse{Version: 5}
+	build(metadata)
+	return sarama.NewMockWrapper(metadata)
+}
+
+func TestTopicEventsLeaderNotPreferred(t *testing.T) {
+	first := sarama.NewMockBroker(t, 1)
+	defer first.Close()
+	second := sarama.NewMockBroker(t, 2)
+	defer second.Close()
+	first.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": newMetadataWrapper(func(metadata *sarama.MetadataResponse) {
+			metadata.AddBroker(first.Addr(), first.BrokerID())
+			metadata.AddBroker(second.Addr(), second.BrokerID())
+			metadata.AddTopicPartition("orders", 0, 1, []int32{1, 2}, []int32{1, 2}, nil, sarama.ErrNoError)
+			metadata.AddTopicPartition("orders", 1, 1, []int32{2, 1}, []int32{2, 1}, nil, sarama.ErrNoError)
+		}),
+	})
+	connectTestClient(t, first, nil)
+	defer client.Close()
+
+	events := topicEvents("orders", map[int32]int64{0: 10, 1: 20})
+	if len(events) != 2 {
+		t.Fatalf("expected 2 topic events, got %v", events)
+	}
+	for _, event := range events {
+		pid := event["partition"].(int32)
+		preferred := map[int32]int32{0: 1, 1: 2}[pid]
+		if event["preferredLeader"] != preferred {
+			t.Errorf("expected preferred leader %v for partition %v, got %v", preferred, pid, event["preferredLeader"])
+		}
+		if event["leaderNotPreferred"] != (pid == 1) {
+			t.Errorf("expected leaderNotPreferred %v for partition %v, got %v", pid == 1, pid, event["leaderNotPreferred"])
+		}
+	}
+}
+
+func TestEventsHaveEveryField(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "modern", broker).
+			SetError(sarama.CoordinatorGroup, "legacy", sarama.ErrGroupAuthorizationFailed),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("modern", "orders", 0, 7, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	// failed coordinator lookups are otherwise retried with backoff
+	conf := sarama.NewConfig()
+	conf.Metadata.Retry.Max = 0
+	connectTestClient(t, broker, conf)
+	defer client.Close()
+	defer stubZookeeperOffsets(map[string]map[string]map[int32]int64{"legacy": {"orders": {0: 3}}})()
+
+	// the legacy group's coordinator can't be found and the second partition isn't in the metadata
+	consumers := processGroups([]string{"modern", "legacy"}, "orders", map[int32]int64{0: 10})
+	if len(consumers) != 2 {
+		t.Fatalf("expected a consumer event per group, got %v", consumers)
+	}
+	topics := topicEvents("orders", map[int32]int64{0: 10, 1: 20})
+	if len(topics) != 2 {
+		t.Fatalf("expected a topic event per partition, got %v", topics)
+	}
+	for _, event := range consumers {
+		for _, field := range []string{"lag", "offset", "coordinator", "logEndOffset"} {
+			if _, ok := event[field]; !ok {
+				t.Errorf("expected the consumer event to have %v, got %v", field, event)
+			}
+		}
+	}
+	for _, event := range topics {
+		for _, field := range []string{"size", "leader", "preferredLeader", "leaderNotPreferred", "replicaAssignment"} {
+			if _, ok := event[field]; !ok {
+				t.Errorf("expected the topic event to have %v, got %v", field, event)
+			}
+		}
+	}
+	if consumers[0]["group"] != "legacy" || consumers[0]["coordinator"] != unknownBroker {
+		t.Errorf("expected the legacy group's coordinator to be unknown, got %v", consumers[0])
+	}
+	if consumers[1]["coordinator"] != broker.BrokerID() {
+		t.Errorf("expected the modern group's coordinator to be %v, got %v", broker.BrokerID(), consumers[1])
+	}
+	if topics[1]["leader"] != unknownBroker || topics[1]["preferredLeader"] != unknownBroker {
+		t.Errorf("expected the leaders of the unknown partition to be unknown, got %v", topics[1])
+	}
+}
+
+func TestPartitionCountChanged(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10).
+			SetOffset("orders", 1, sarama.OffsetNewest, 10).
+			SetOffset("orders", 2, sarama.OffsetNewest, 10),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+
+	bt := New()
+	bt.topics = []string{"orders"}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	first := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: first})
+	for _, event := range first.ofType("topic") {
+		if _, ok := event["partitionCountChanged"]; ok {
+			t.Errorf("expected no change marker for a topic seen for the first time, got %v", event)
+		}
+	}
+
+	metadata.SetLeader("orders", 2, broker.BrokerID())
+	if err := client.RefreshMetadata("orders"); err != nil {
+		t.Fatal(err)
+	}
+	second := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: second})
+	events := second.ofType("topic")
+	if len(events) != 3 {
+		t.Fatalf("expected an event per partition after scaling, got %v", events)
+	}
+	for _, event := range events {
+		if event["partitionCountChanged"] != true || event["previousPartitionCount"] != 2 {
+			t.Errorf("expected a change marker from 2 partitions, got %v", event)
+		}
+	}
+}
+
+func TestGetPartitionSizesStops(t *testing.T) {
+	partitions := int32(200)
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": partitions})
+	defer broker.Close()
+	offsets := sarama.NewMockOffsetResponse(t)
+	pids := make([]int32, partitions)
+	for pid := int32(0); pid < partitions; pid++ {
+		offsets.SetOffset("orders", pid, sarama.OffsetNewest, 10)
+		pids[pid] = pid
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest":   offsets,
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	broker.SetLatency(10 * time.Millisecond)
+
+	done := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(done) })
+	start := time.Now()
+	sizes := getPartitionSizes("orders", pids, highWatermark, 1, done)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected sizing to stop promptly once done was closed, took %v", elapsed)
+	}
+	if len(sizes) == 0 || len(sizes) == int(partitions) {
+		t.Errorf("expected a partial set of sizes, got %v of %v", len(sizes), partitions)
+	}
+}
+
+// capturePublisher collects the events published through it
+type capturePublisher struct {
+	events []common.MapStr
+}
+
+func (p *capturePublisher) PublishEvent(event common.MapStr, opts ...publisher.ClientOption) bool {
+	p.events = append(p.events, event)
+	return true
+}
+
+func (p *capturePublisher) PublishEvents(events []common.MapStr, opts ...publisher.ClientOption) bool {
+	p.events = append(p.events, events...)
+	return true
+}
+
+// ofType returns the published events of the given type
+func (p *capturePublisher) ofType(eventType string) []common.MapStr {
+	var events []common.MapStr
+	for _, event := range p.events {
+		if event["type"] == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestGroupDiscoveryFailureMonitorsTopics(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest":   sarama.NewMockOffsetResponse(t).SetOffset("orders", 0, sarama.OffsetNewest, 10),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer func(original func() ([]string, error)) { zookeeperGroups = original }(zookeeperGroups)
+	zookeeperGroups = func() ([]string, error) {
+		return nil, zk.ErrNoNode
+	}
+
+	bt := New()
+	bt.beatConfig = &config.Config{}
+	bt.offsetBasis = highWatermark
+	if err := bt.resolve(); err != nil {
+		t.Fatalf("expected group discovery failure not to fail resolution, got %v", err)
+	}
+	if len(bt.groups) != 0 {
+		t.Errorf("expected no groups to be monitored, got %v", bt.groups)
+	}
+
+	events := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: events})
+	if topics := events.ofType("topic"); len(topics) != 1 {
+		t.Errorf("expected a topic event, got %v", events.events)
+	}
+}
+
+func TestEventsInPartitionOrder(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 8})
+	defer broker.Close()
+	sizes := sarama.NewMockOffsetResponse(t)
+	offsets := sarama.NewMockOffsetFetchResponse(t)
+	for pid := int32(0); pid < 8; pid++ {
+		sizes.SetOffset("orders", pid, sarama.OffsetNewest, 10)
+		offsets.SetOffset("billing", "orders", pid, 4, "", sarama.ErrNoError).
+			SetOffset("audit", "orders", pid, 2, "", sarama.ErrNoError)
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest":   sizes,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "audit", broker),
+		"OffsetFetchRequest":    offsets,
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	bt := New()
+	bt.topics = []string{"orders"}
+	bt.groups = []string{"billing", "audit"}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	bt.concurrency = 4
+	events := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: events})
+
+	topics := events.ofType("topic")
+	if len(topics) != 8 {
+		t.Fatalf("expected an event per partition, got %v", topics)
+	}
+	for i, event := range topics {
+		if event["partition"] != int32(i) {
+			t.Errorf("expected topic event %v to be for partition %v, got %v", i, i, event["partition"])
+		}
+	}
+	consumers := events.ofType("consumer")
+	if len(consumers) != 16 {
+		t.Fatalf("expected an event per group and partition, got %v", consumers)
+	}
+	for i, event := range consumers {
+		group := []string{"audit", "billing"}[i/8]
+		if event["group"] != group || event["partition"] != int32(i%8) {
+			t.Errorf("expected consumer event %v to be for %v partition %v, got %v/%v", i, group, i%8, event["group"], event["partition"])
+		}
+	}
+}
+
+func TestRefreshBrokersRemoved(t *testing.T) {
+	first, _ := newTestBroker(t, map[string]int32{"orders": 1})
+	defer first.Close()
+	second := sarama.NewMockBroker(t, 2)
+	defer second.Close()
+	both := sarama.NewMockMetadataResponse(t).
+		SetBroker(first.Addr(), first.BrokerID()).
+		SetBroker(second.Addr(), second.BrokerID()).
+		SetLeader("orders", 0, first.BrokerID())
+	first.SetHandlerByMap(map[string]sarama.MockResponse{"MetadataRequest": both})
+	second.SetHandlerByMap(map[string]sarama.MockResponse{"MetadataRequest": both})
+	connectTestClient(t, first, nil)
+	defer client.Close()
+	if err := client.RefreshMetadata(); err != nil {
+		t.Fatal(err)
+	}
+
+	remaining := sarama.NewMockMetadataResponse(t).
+		SetBroker(first.Addr(), first.BrokerID()).
+		SetLeader("orders", 0, first.BrokerID())
+	first.SetHandlerByMap(map[string]sarama.MockResponse{"MetadataRequest": remaining})
+	defer func(original func() ([]string, error)) { brokerList = original }(brokerList)
+	brokerList = func() ([]string, error) {
+		return []string{first.Addr()}, nil
+	}
+
+	bt := New()
+	bt.brokers = []string{first.Addr(), second.Addr()}
+	published := &capturePublisher{}
+	if err := bt.refreshBrokers(&beat.Beat{Events: published}); err != nil {
+		t.Fatal(err)
+	}
+	removed := published.ofType("broker_removed")
+	if len(removed) != 1 || removed[0]["broker"] != second.Addr() || removed[0]["brokerId"] != int32(2) {
+		t.Errorf("expected an event for the broker that left, got %v", removed)
+	}
+	if added := published.ofType("broker_added"); len(added) != 0 {
+		t.Errorf("expected no broker to have joined, got %v", added)
+	}
+}
+
+func TestGroupsShareSizeSnapshot(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10).
+			SetOffset("orders", 1, sarama.OffsetNewest, 20),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "audit", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "search", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 4, "", sarama.ErrNoError).
+			SetOffset("billing", "orders", 1, 5, "", sarama.ErrNoError).
+			SetOffset("audit", "orders", 0, 2, "", sarama.ErrNoError).
+			SetOffset("audit", "orders", 1, 3, "", sarama.ErrNoError).
+			SetOffset("search", "orders", 0, 9, "", sarama.ErrNoError).
+			SetOffset("search", "orders", 1, 19, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	bt := New()
+	bt.topics = []string{"orders"}
+	bt.groups = []string{"audit", "billing", "search"}
+	bt.offsetBasis = highWatermark
+	events := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: events})
+
+	consumers := events.ofType("consumer")
+	if len(consumers) != 6 {
+		t.Fatalf("expected an event per group and partition, got %v", consumers)
+	}
+	for _, event := range consumers {
+		size := map[int32]int64{0: 10, 1: 20}[event["partition"].(int32)]
+		if event["logEndOffset"] != size || event["lag"] != size-event["offset"].(int64) {
+			t.Errorf("expected %v's lag on partition %v to be taken against the size %v, got %v", event["group"], event["partition"], size, event)
+		}
+	}
+	sized := 0
+	for _, exchange := range broker.History() {
+		if _, ok := exchange.Request.(*sarama.OffsetRequest); ok {
+			sized++
+		}
+	}
+	if sized != 2 {
+		t.Errorf("expected each partition to be sized once for all the groups, got %v offset requests", sized)
+	}
+}
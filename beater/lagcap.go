@@ -0,0 +1,23 @@
+package beater
+
+import "github.com/elastic/beats/libbeat/common"
+
+// capLag clamps the lag of consumer events, and the total lag of group rollups, to lag_cap, so that groups
+// reading a huge topic from its start don't skew the scale of charts. Clamped events are flagged lagCapped
+// with the uncapped lag kept as rawLag, or rawTotalLag for rollups
+func (bt *Kafkabeat) capLag(events []common.MapStr) {
+	if bt.lagCap <= 0 {
+		return
+	}
+	for _, event := range events {
+		field, raw := "lag", "rawLag"
+		if event["type"] == "group_topic" {
+			field, raw = "totalLag", "rawTotalLag"
+		}
+		lag, ok := event[field].(int64)
+		if !ok || lag <= bt.lagCap {
+			continue
+		}
+		event.Update(common.MapStr{field: bt.lagCap, raw: lag, "lagCapped": true})
+	}
+}
@@ -0,0 +1,29 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestLagCap(t *testing.T) {
+	bt := New()
+	bt.lagCap = 1000000
+	events := []common.MapStr{
+		{"type": "consumer", "group": "backfill", "topic": "clicks", "lag": int64(4000000000)},
+		{"type": "consumer", "group": "billing", "topic": "clicks", "lag": int64(20)},
+		{"type": "group_topic", "group": "backfill", "topic": "clicks", "totalLag": int64(12000000000)},
+	}
+	bt.capLag(events)
+	capped := events[0]
+	if capped["lag"] != int64(1000000) || capped["rawLag"] != int64(4000000000) || capped["lagCapped"] != true {
+		t.Errorf("expected the lag to be clamped to the cap and flagged, got %v", capped)
+	}
+	if _, ok := events[1]["lagCapped"]; ok || events[1]["lag"] != int64(20) {
+		t.Errorf("expected a lag below the cap to be left alone, got %v", events[1])
+	}
+	rollup := events[2]
+	if rollup["totalLag"] != int64(1000000) || rollup["rawTotalLag"] != int64(12000000000) || rollup["lagCapped"] != true {
+		t.Errorf("expected the rollup's total lag to be clamped and flagged, got %v", rollup)
+	}
+}
@@ -0,0 +1,64 @@
+package beater
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// brokerLatencies times the offset requests made to each broker over a tick
+var brokerLatencies = newLatencies()
+
+type latencies struct {
+	lock    sync.Mutex
+	samples map[int32][]time.Duration
+}
+
+func newLatencies() *latencies {
+	return &latencies{samples: make(map[int32][]time.Duration)}
+}
+
+// since records the time taken by a request to the broker issued at start
+func (l *latencies) since(broker int32, start time.Time) {
+	l.record(broker, time.Since(start))
+}
+
+func (l *latencies) record(broker int32, took time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.samples[broker] = append(l.samples[broker], took)
+}
+
+// events builds a broker_latency event per broker from the samples recorded since the last call
+func (l *latencies) events() []common.MapStr {
+	l.lock.Lock()
+	samples := l.samples
+	l.samples = make(map[int32][]time.Duration)
+	l.lock.Unlock()
+
+	events := make([]common.MapStr, 0, len(samples))
+	for broker, durations := range samples {
+		sort.Sort(byDuration(durations))
+		events = append(events, common.MapStr{
+			"@timestamp": common.Time(time.Now()),
+			"type":       "broker_latency",
+			"brokerId":   broker,
+			"requests":   len(durations),
+			"p50Ms":      milliseconds(durations[(len(durations)+1)/2-1]),
+			"maxMs":      milliseconds(durations[len(durations)-1]),
+		})
+	}
+	return events
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+type byDuration []time.Duration
+
+func (d byDuration) Len() int           { return len(d) }
+func (d byDuration) Less(i, j int) bool { return d[i] < d[j] }
+func (d byDuration) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
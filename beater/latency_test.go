@@ -0,0 +1,54 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestBrokerLatencyEvents(t *testing.T) {
+	recorded := newLatencies()
+	for _, ms := range []time.Duration{30, 10, 20} {
+		recorded.record(1, ms*time.Millisecond)
+	}
+	recorded.record(2, 5*time.Millisecond)
+
+	events := recorded.events()
+	if len(events) != 2 {
+		t.Fatalf("expected an event per broker, got %v", events)
+	}
+	for _, event := range events {
+		expected := map[int32][2]float64{1: {20, 30}, 2: {5, 5}}[event["brokerId"].(int32)]
+		if event["p50Ms"] != expected[0] || event["maxMs"] != expected[1] {
+			t.Errorf("expected p50 %v and max %v for broker %v, got %v", expected[0], expected[1], event["brokerId"], event)
+		}
+	}
+	if len(recorded.events()) != 0 {
+		t.Errorf("expected the samples to be reset after building events")
+	}
+}
+
+func TestBrokerLatencyFromOffsetRequests(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10).
+			SetOffset("orders", 1, sarama.OffsetNewest, 10),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	brokerLatencies.events()
+	broker.SetLatency(20 * time.Millisecond)
+
+	getPartitionSizes("orders", []int32{0, 1}, highWatermark, 1, nil)
+	events := brokerLatencies.events()
+	if len(events) != 1 || events[0]["brokerId"] != broker.BrokerID() {
+		t.Fatalf("expected a latency event for the broker, got %v", events)
+	}
+	if events[0]["requests"] != 2 || events[0]["p50Ms"].(float64) < 20 {
+		t.Errorf("expected 2 requests taking at least 20ms, got %v", events[0])
+	}
+}
@@ -0,0 +1,58 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// skipLeaderless has partitions without a leader, as during elections and broker maintenance, left out of
+// sizing rather than failing to size them, a leaderless event being published for each in place of an error
+var skipLeaderless = true
+
+// leaderlessPartitions splits the partitions with no leader off the others. The leaders are those of the
+// cached metadata, as looking each partition's up refreshes the metadata for every one without a leader
+func leaderlessPartitions(topic string, pids []int32) ([]int32, []int32) {
+	if !skipLeaderless {
+		return pids, nil
+	}
+	writable, err := client.WritablePartitions(topic)
+	if err != nil {
+		logp.Debug("kafkabeat", "Unable to tell the partitions of topic %s without a leader: %v", topic, err)
+		return pids, nil
+	}
+	var led, leaderless []int32
+	for _, pid := range pids {
+		if !containsPartition(writable, pid) {
+			leaderless = append(leaderless, pid)
+			continue
+		}
+		led = append(led, pid)
+	}
+	return led, leaderless
+}
+
+// leaderlessEvents builds a topic event flagged leaderless for each partition with no leader, its size unknown
+func leaderlessEvents(topic string, pids []int32) []common.MapStr {
+	events := make([]common.MapStr, 0, len(pids))
+	for _, pid := range pids {
+		event := common.MapStr{
+			"@timestamp":         common.Time(time.Now()),
+			"type":               "topic",
+			"partition":          pid,
+			"topic":              topic,
+			"size":               unknownOffset,
+			"replicaAssignment":  []int32{},
+			"preferredLeader":    unknownBroker,
+			"leader":             unknownBroker,
+			"leaderNotPreferred": false,
+			"leaderless":         true,
+		}
+		if replicas, err := client.Replicas(topic, pid); err == nil && len(replicas) > 0 {
+			event.Update(common.MapStr{"replicaAssignment": replicas, "preferredLeader": replicas[0]})
+		}
+		events = append(events, event)
+	}
+	return events
+}
@@ -0,0 +1,64 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestSkipLeaderless(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": newMetadataWrapper(func(metadata *sarama.MetadataResponse) {
+			metadata.AddBroker(broker.Addr(), broker.BrokerID())
+			metadata.AddTopicPartition("orders", 0, 1, []int32{1}, []int32{1}, nil, sarama.ErrNoError)
+			// mid election
+			metadata.AddTopicPartition("orders", 1, -1, []int32{1}, []int32{}, nil, sarama.ErrLeaderNotAvailable)
+		}),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).SetOffset("orders", 0, sarama.OffsetNewest, 10),
+	})
+	// metadata missing a leader is otherwise retried with backoff
+	conf := sarama.NewConfig()
+	conf.Metadata.Retry.Max = 0
+	connectTestClient(t, broker, conf)
+	defer client.Close()
+	collectionErrors.enabled = true
+	defer func() { collectionErrors.enabled = false }()
+
+	bt := New()
+	bt.topics = []string{"orders"}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	metadataRequests := func() int {
+		count := 0
+		for _, exchange := range broker.History() {
+			if _, ok := exchange.Request.(*sarama.MetadataRequest); ok {
+				count++
+			}
+		}
+		return count
+	}
+	connected := metadataRequests()
+	published := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: published})
+	// the leaderless partition is told apart from the cached metadata, which stays as it is
+	if metadataRequests() != connected {
+		t.Errorf("expected no metadata refresh for the leaderless partition, got %v after %v", metadataRequests(), connected)
+	}
+
+	topics := published.ofType("topic")
+	if len(topics) != 2 {
+		t.Fatalf("expected a topic event per partition, got %v", topics)
+	}
+	for _, event := range topics {
+		leaderless := event["partition"] == int32(1)
+		if (event["leaderless"] == true) != leaderless {
+			t.Errorf("expected leaderless %v for partition %v, got %v", leaderless, event["partition"], event)
+		}
+	}
+	if errors := collectionErrors.drain(); len(errors) != 0 {
+		t.Errorf("expected no errors for the leaderless partition, got %v", errors)
+	}
+}
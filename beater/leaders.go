@@ -0,0 +1,96 @@
+package beater
+
+import (
+	"strconv"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// leadershipCounts counts the partitions of the topics each broker leads. Only partitions with a leader in
+// the cached metadata are looked up, as looking up one without refreshes the metadata
+func leadershipCounts(topics []string) map[int32]int {
+	counts := make(map[int32]int)
+	for _, topic := range topics {
+		pids, err := client.WritablePartitions(topic)
+		if err != nil {
+			logp.Err("Unable to retrieve partitions for topic %s", topic)
+			continue
+		}
+		for _, pid := range pids {
+			leader, err := client.Leader(topic, pid)
+			if err == nil {
+				counts[leader.ID()]++
+			}
+		}
+	}
+	return counts
+}
+
+// markHotBrokers flags the topic events whose leader leads more than the average share of partitions
+// across the brokers of the cluster
+func markHotBrokers(events []common.MapStr, counts map[int32]int) {
+	brokers := len(client.Brokers())
+	if brokers == 0 {
+		return
+	}
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	average := float64(total) / float64(brokers)
+	for _, event := range events {
+		if leader, ok := event["leader"].(int32); ok {
+			event["onHotBroker"] = float64(counts[leader]) > average
+		}
+	}
+}
+
+// leadersOf maps each partition to the broker id leading it, as read into the partition's topic event
+func leadersOf(partitions []common.MapStr) map[int32]int32 {
+	leaders := make(map[int32]int32)
+	for _, event := range partitions {
+		if leader, ok := event["leader"].(int32); ok && leader != unknownBroker {
+			leaders[event["partition"].(int32)] = leader
+		}
+	}
+	return leaders
+}
+
+// markLeaderLag breaks the lag of each group_topic rollup down by the brokers leading the topic's partitions,
+// as lagByLeader summing the lag of the partitions each broker id leads, to tie a group's lag to broker
+// hotspots. Partitions whose leader or log end is unknown are left out
+func markLeaderLag(leaders map[int32]int32, rollups []common.MapStr, consumers []common.MapStr) {
+	breakdowns := make(map[string]common.MapStr)
+	// groups reported per store have an event per store for the same partition, the first is counted
+	counted := make(map[string]map[int32]bool)
+	for _, event := range consumers {
+		group, pid := event["group"].(string), event["partition"].(int32)
+		if counted[group] == nil {
+			counted[group] = make(map[int32]bool)
+			breakdowns[group] = common.MapStr{}
+		}
+		if counted[group][pid] {
+			continue
+		}
+		counted[group][pid] = true
+		leader, ok := leaders[pid]
+		if !ok {
+			continue
+		}
+		lag, ok := lagOf(event)
+		if !ok {
+			continue
+		}
+		key := strconv.Itoa(int(leader))
+		summed, _ := breakdowns[group][key].(int64)
+		breakdowns[group][key] = summed + lag
+	}
+	for _, rollup := range rollups {
+		breakdown, ok := breakdowns[rollup["group"].(string)]
+		if !ok {
+			breakdown = common.MapStr{}
+		}
+		rollup["lagByLeader"] = breakdown
+	}
+}
@@ -0,0 +1,83 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestMarkHotBrokers(t *testing.T) {
+	first := sarama.NewMockBroker(t, 1)
+	defer first.Close()
+	second := sarama.NewMockBroker(t, 2)
+	defer second.Close()
+	first.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": newMetadataWrapper(func(metadata *sarama.MetadataResponse) {
+			metadata.AddBroker(first.Addr(), first.BrokerID())
+			metadata.AddBroker(second.Addr(), second.BrokerID())
+			// broker 1 leads three of the four partitions
+			for pid, leader := range []int32{1, 1, 1, 2} {
+				metadata.AddTopicPartition("orders", int32(pid), leader, []int32{leader}, []int32{leader}, nil, sarama.ErrNoError)
+			}
+		}),
+	})
+	connectTestClient(t, first, nil)
+	defer client.Close()
+
+	counts := leadershipCounts([]string{"orders"})
+	if counts[1] != 3 || counts[2] != 1 {
+		t.Fatalf("expected broker 1 to lead 3 partitions and broker 2 one, got %v", counts)
+	}
+	events := topicEvents("orders", map[int32]int64{0: 10, 1: 10, 2: 10, 3: 10})
+	markHotBrokers(events, counts)
+	for _, event := range events {
+		hot := event["leader"] == int32(1)
+		if event["onHotBroker"] != hot {
+			t.Errorf("expected onHotBroker %v for partition %v led by %v, got %v", hot, event["partition"], event["leader"], event["onHotBroker"])
+		}
+	}
+}
+
+func TestMarkLeaderLag(t *testing.T) {
+	// leaders as read into the topic events, partition 3's being unknown
+	partitions := []common.MapStr{
+		{"type": "topic", "topic": "orders", "partition": int32(0), "leader": int32(1)},
+		{"type": "topic", "topic": "orders", "partition": int32(1), "leader": int32(2)},
+		{"type": "topic", "topic": "orders", "partition": int32(2), "leader": int32(1)},
+		{"type": "topic", "topic": "orders", "partition": int32(3), "leader": unknownBroker},
+	}
+	consumer := func(group string, pid int32, lag int64) common.MapStr {
+		return common.MapStr{"type": "consumer", "topic": "orders", "group": group, "partition": pid, "lag": lag, "logEndOffset": int64(100)}
+	}
+	consumers := []common.MapStr{
+		consumer("billing", 0, 5),
+		consumer("billing", 1, 7),
+		consumer("billing", 2, 11),
+		// the same partition read from a second store is counted once
+		consumer("billing", 2, 30),
+		consumer("audit", 1, 3),
+		consumer("audit", 3, 9),
+		// the log end of partition 0 couldn't be read for audit
+		{"type": "consumer", "topic": "orders", "group": "audit", "partition": int32(0), "lag": unknownOffset, "logEndOffset": unknownOffset},
+	}
+	rollups := groupTopicEvents("orders", map[int32]int64{0: 100, 1: 100, 2: 100, 3: 100}, consumers)
+	markLeaderLag(leadersOf(partitions), rollups, consumers)
+	expected := map[string]common.MapStr{
+		"billing": {"1": int64(16), "2": int64(7)},
+		"audit":   {"2": int64(3)},
+	}
+	for _, rollup := range rollups {
+		group := rollup["group"].(string)
+		breakdown := rollup["lagByLeader"].(common.MapStr)
+		if len(breakdown) != len(expected[group]) {
+			t.Errorf("expected the lag of %v broken down as %v, got %v", group, expected[group], breakdown)
+			continue
+		}
+		for leader, lag := range expected[group] {
+			if breakdown[leader] != lag {
+				t.Errorf("expected the lag of %v broken down as %v, got %v", group, expected[group], breakdown)
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// diskUsage is the bytes each partition's current log takes up on each broker, and each broker's total
+type diskUsage struct {
+	partitions map[int32]map[string]map[int32]int64
+	brokers    map[int32]int64
+}
+
+// getDiskUsage describes the log dirs of every broker, leaving out those the request fails for
+func getDiskUsage() *diskUsage {
+	usage := &diskUsage{partitions: make(map[int32]map[string]map[int32]int64), brokers: make(map[int32]int64)}
+	for _, broker := range client.Brokers() {
+		err := broker.Open(client.Config())
+		if err != nil && err != sarama.ErrAlreadyConnected {
+			logp.Err("Unable to connect to broker %v to describe its log dirs: %v", broker.ID(), err)
+			continue
+		}
+		res, err := broker.DescribeLogDirs(&sarama.DescribeLogDirsRequest{})
+		if err != nil {
+			logp.Err("Unable to describe the log dirs of broker %v: %v", broker.ID(), err)
+			continue
+		}
+		usage.add(broker.ID(), res)
+	}
+	return usage
+}
+
+func (usage *diskUsage) add(broker int32, res *sarama.DescribeLogDirsResponse) {
+	partitions := make(map[string]map[int32]int64)
+	total := int64(0)
+	for _, dir := range res.LogDirs {
+		if dir.ErrorCode != sarama.ErrNoError {
+			logp.Warn("Log dir %s of broker %v is unavailable: %v", dir.Path, broker, dir.ErrorCode)
+			continue
+		}
+		for _, topic := range dir.Topics {
+			if partitions[topic.Topic] == nil {
+				partitions[topic.Topic] = make(map[int32]int64)
+			}
+			for _, partition := range topic.Partitions {
+				total += partition.Size
+				// future logs being moved between dirs are not yet the partition's
+				if !partition.IsTemporary {
+					partitions[topic.Topic][partition.PartitionID] += partition.Size
+				}
+			}
+		}
+	}
+	usage.partitions[broker] = partitions
+	usage.brokers[broker] = total
+}
+
+// mark adds the bytes each partition's log takes up on its leader to the partition's topic event
+func (usage *diskUsage) mark(events []common.MapStr) {
+	if usage == nil {
+		return
+	}
+	for _, event := range events {
+		leader, ok := event["leader"].(int32)
+		if !ok {
+			continue
+		}
+		size, ok := usage.partitions[leader][event["topic"].(string)][event["partition"].(int32)]
+		if ok {
+			event["partitionBytes"] = size
+		}
+	}
+}
+
+// events builds an event per broker with the bytes its log dirs take up
+func (usage *diskUsage) events() []common.MapStr {
+	if usage == nil {
+		return nil
+	}
+	events := make([]common.MapStr, 0, len(usage.brokers))
+	for broker, total := range usage.brokers {
+		events = append(events, common.MapStr{
+			"@timestamp":      common.Time(time.Now()),
+			"type":            "broker_disk",
+			"brokerId":        broker,
+			"brokerDiskBytes": total,
+		})
+	}
+	return events
+}
@@ -0,0 +1,55 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestPartitionBytes(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10).
+			SetOffset("orders", 1, sarama.OffsetNewest, 10),
+		"DescribeLogDirsRequest": sarama.NewMockWrapper(&sarama.DescribeLogDirsResponse{
+			LogDirs: []sarama.DescribeLogDirsResponseDirMetadata{
+				{ErrorCode: sarama.ErrNoError, Path: "/data/1", Topics: []sarama.DescribeLogDirsResponseTopic{
+					{Topic: "orders", Partitions: []sarama.DescribeLogDirsResponsePartition{
+						{PartitionID: 0, Size: 1000},
+						// partition 1 being moved to the second dir
+						{PartitionID: 1, Size: 300, IsTemporary: true},
+					}},
+				}},
+				{ErrorCode: sarama.ErrNoError, Path: "/data/2", Topics: []sarama.DescribeLogDirsResponseTopic{
+					{Topic: "orders", Partitions: []sarama.DescribeLogDirsResponsePartition{{PartitionID: 1, Size: 500}}},
+				}},
+				{ErrorCode: sarama.ErrKafkaStorageError, Path: "/data/3"},
+			},
+		}),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+
+	bt := New()
+	bt.topics = []string{"orders"}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	bt.partitionBytes = true
+	events := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: events})
+
+	for _, event := range events.ofType("topic") {
+		expected := map[int32]int64{0: 1000, 1: 500}[event["partition"].(int32)]
+		if event["partitionBytes"] != expected {
+			t.Errorf("expected partition %v to take up %v bytes, got %v", event["partition"], expected, event["partitionBytes"])
+		}
+	}
+	disks := events.ofType("broker_disk")
+	if len(disks) != 1 || disks[0]["brokerId"] != broker.BrokerID() || disks[0]["brokerDiskBytes"] != int64(1800) {
+		t.Errorf("expected the broker's log dirs to total 1800 bytes, got %v", disks)
+	}
+}
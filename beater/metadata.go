@@ -0,0 +1,31 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+// applyMetadataConfig tunes how often and how persistently the client refreshes the cluster metadata,
+// leaving sarama's defaults for those not configured
+func applyMetadataConfig(conf config.MetadataConfig, saramaConfig *sarama.Config) error {
+	if conf.RefreshFrequency != "" {
+		frequency, err := time.ParseDuration(conf.RefreshFrequency)
+		if err != nil {
+			return err
+		}
+		saramaConfig.Metadata.RefreshFrequency = frequency
+	}
+	if conf.Retries != nil {
+		saramaConfig.Metadata.Retry.Max = *conf.Retries
+	}
+	if conf.RetryBackoff != "" {
+		backoff, err := time.ParseDuration(conf.RetryBackoff)
+		if err != nil {
+			return err
+		}
+		saramaConfig.Metadata.Retry.Backoff = backoff
+	}
+	return saramaConfig.Validate()
+}
@@ -0,0 +1,35 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+func TestMetadataConfig(t *testing.T) {
+	retries := 0
+	saramaConfig := sarama.NewConfig()
+	err := applyMetadataConfig(config.MetadataConfig{RefreshFrequency: "1m", Retries: &retries, RetryBackoff: "2s"}, saramaConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saramaConfig.Metadata.RefreshFrequency != time.Minute || saramaConfig.Metadata.Retry.Max != 0 ||
+		saramaConfig.Metadata.Retry.Backoff != 2*time.Second {
+		t.Errorf("expected the metadata settings to be taken from config, got %+v", saramaConfig.Metadata)
+	}
+
+	defaults := sarama.NewConfig()
+	saramaConfig = sarama.NewConfig()
+	if err := applyMetadataConfig(config.MetadataConfig{}, saramaConfig); err != nil {
+		t.Fatal(err)
+	}
+	if saramaConfig.Metadata.RefreshFrequency != defaults.Metadata.RefreshFrequency ||
+		saramaConfig.Metadata.Retry.Max != defaults.Metadata.Retry.Max {
+		t.Errorf("expected sarama's defaults when unset, got %+v", saramaConfig.Metadata)
+	}
+	if err := applyMetadataConfig(config.MetadataConfig{RetryBackoff: "soon"}, sarama.NewConfig()); err == nil {
+		t.Errorf("expected an unparseable backoff to be rejected")
+	}
+}
@@ -0,0 +1,26 @@
+package beater
+
+import "github.com/elastic/beats/libbeat/logp"
+
+// totalSize sums the sizes of a topic's partitions
+func totalSize(pids map[int32]int64) int64 {
+	total := int64(0)
+	for _, size := range pids {
+		total += size
+	}
+	return total
+}
+
+// belowMinTopicSize is whether the topic totals less than min_topic_size across its partitions, its
+// events being left out to trim the long tail of empty and barely used topics
+func (bt *Kafkabeat) belowMinTopicSize(topic string, pids map[int32]int64) bool {
+	if bt.minTopicSize <= 0 {
+		return false
+	}
+	total := totalSize(pids)
+	if total >= bt.minTopicSize {
+		return false
+	}
+	logp.Debug("kafkabeat", "Skipping topic %s totalling %v, below min_topic_size", topic, total)
+	return true
+}
@@ -0,0 +1,39 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestMinTopicSize(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2, "unused": 2})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 60).
+			SetOffset("orders", 1, sarama.OffsetNewest, 40).
+			SetOffset("unused", 0, sarama.OffsetNewest, 0).
+			SetOffset("unused", 1, sarama.OffsetNewest, 3),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+
+	bt := New()
+	bt.topics = []string{"orders", "unused"}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	bt.minTopicSize = 10
+	published := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: published})
+	for _, event := range published.events {
+		if event["topic"] == "unused" {
+			t.Errorf("expected nothing for a topic below min_topic_size, got %v", event)
+		}
+	}
+	if topics := published.ofType("topic"); len(topics) != 2 {
+		t.Errorf("expected the events of the topic above min_topic_size, got %v", topics)
+	}
+}
@@ -0,0 +1,116 @@
+package beater
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+// mirror compares topics replicated from a source cluster with their copies on the monitored cluster
+type mirror struct {
+	source sarama.Client
+	// source topic to the topic it is replicated to on the monitored cluster
+	topics map[string]string
+}
+
+func newMirror(conf config.MirrorConfig, saramaConfig *sarama.Config) (*mirror, error) {
+	sourceConfig, err := mirrorConfig(conf, saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+	source, err := sarama.NewClient(conf.SourceBrokers, sourceConfig)
+	if err != nil {
+		return nil, err
+	}
+	topics := conf.Topics
+	if topics == nil {
+		topics = make(map[string]string)
+	}
+	return &mirror{source: source, topics: topics}, nil
+}
+
+// mirrorConfig is the client config of the source cluster, authenticating with the source's own SASL settings
+// when it has them, so that a SASL cluster and a plaintext one can be compared. Without them the source
+// authenticates as the monitored cluster does
+func mirrorConfig(conf config.MirrorConfig, saramaConfig *sarama.Config) (*sarama.Config, error) {
+	if conf.SASL == nil {
+		return saramaConfig, nil
+	}
+	sourceConfig := *saramaConfig
+	sourceConfig.Net.SASL = sarama.NewConfig().Net.SASL
+	if err := applySASLConfig(*conf.SASL, &sourceConfig); err != nil {
+		return nil, fmt.Errorf("mirror: %v", err)
+	}
+	if err := sourceConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("mirror: %v", err)
+	}
+	return &sourceConfig, nil
+}
+
+// lagEvents sizes every mirrored topic on both clusters and builds its mirror lag events
+func (m *mirror) lagEvents() []common.MapStr {
+	var events []common.MapStr
+	for source, target := range m.topics {
+		sourceSizes, err := logEndOffsets(m.source, source)
+		if err != nil {
+			logp.Err("Unable to size source topic %s: %v", source, err)
+			continue
+		}
+		targetSizes, err := logEndOffsets(client, target)
+		if err != nil {
+			logp.Err("Unable to size target topic %s: %v", target, err)
+			continue
+		}
+		events = append(events, mirrorLagEvents(source, target, sourceSizes, targetSizes)...)
+	}
+	return events
+}
+
+// logEndOffsets returns the newest offset of each partition of the topic on the cluster of c
+func logEndOffsets(c sarama.Client, topic string) (map[int32]int64, error) {
+	pids, err := c.Partitions(topic)
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[int32]int64)
+	for _, pid := range pids {
+		size, err := c.GetOffset(topic, pid, sarama.OffsetNewest)
+		if err != nil {
+			logp.Err("Unable to size partition %v of topic %s", pid, topic)
+			continue
+		}
+		sizes[pid] = size
+	}
+	return sizes, nil
+}
+
+// mirrorLagEvents builds an event per partition found in both copies of a topic with how far the target's
+// log end offset trails the source's. Partitions are aligned by id, which assumes the replicator keeps
+// partitioning and offsets, as replicating into an emptied topic does
+func mirrorLagEvents(source string, target string, sourceSizes map[int32]int64, targetSizes map[int32]int64) []common.MapStr {
+	if len(sourceSizes) != len(targetSizes) {
+		logp.Warn("Mirrored topic %s has %v partitions but its copy %s has %v", source, len(sourceSizes), target, len(targetSizes))
+	}
+	var events []common.MapStr
+	for pid, sourceSize := range sourceSizes {
+		targetSize, ok := targetSizes[pid]
+		if !ok {
+			continue
+		}
+		events = append(events, common.MapStr{
+			"@timestamp":  common.Time(time.Now()),
+			"type":        "mirror_lag",
+			"partition":   pid,
+			"topic":       target,
+			"sourceTopic": source,
+			"sourceSize":  sourceSize,
+			"size":        targetSize,
+			"sourceLag":   sourceSize - targetSize,
+		})
+	}
+	return events
+}
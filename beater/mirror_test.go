@@ -0,0 +1,62 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+func TestMirrorLagEvents(t *testing.T) {
+	events := mirrorLagEvents("orders", "dc1.orders",
+		map[int32]int64{0: 100, 1: 50, 2: 70},
+		map[int32]int64{0: 90, 1: 50})
+	if len(events) != 2 {
+		t.Fatalf("expected events for the partitions on both clusters, got %v", events)
+	}
+	for _, event := range events {
+		expected := map[int32]int64{0: 10, 1: 0}[event["partition"].(int32)]
+		if event["type"] != "mirror_lag" || event["sourceLag"] != expected {
+			t.Errorf("expected a mirror lag of %v, got %v", expected, event)
+		}
+		if event["topic"] != "dc1.orders" || event["sourceTopic"] != "orders" {
+			t.Errorf("expected the event to name both copies of the topic, got %v", event)
+		}
+	}
+}
+
+func TestMirrorAuth(t *testing.T) {
+	oauth := config.SASLConfig{Mechanism: sarama.SASLTypeOAuth, TokenURL: "https://idp.example.com/token", ClientID: "kafkabeat"}
+	monitored := sarama.NewConfig()
+	if err := applySASLConfig(oauth, monitored); err != nil {
+		t.Fatal(err)
+	}
+
+	// a plaintext source alongside the SASL monitored cluster
+	plaintext, err := mirrorConfig(config.MirrorConfig{SASL: &config.SASLConfig{}}, monitored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext.Net.SASL.Enable || plaintext.Net.SASL.TokenProvider != nil {
+		t.Errorf("expected the source to connect in plaintext, got %+v", plaintext.Net.SASL)
+	}
+	if !monitored.Net.SASL.Enable || monitored.Net.SASL.Mechanism != sarama.SASLTypeOAuth {
+		t.Errorf("expected the monitored cluster to keep its own SASL settings, got %+v", monitored.Net.SASL)
+	}
+
+	// a SASL source alongside a plaintext monitored cluster
+	secured, err := mirrorConfig(config.MirrorConfig{SASL: &oauth}, sarama.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !secured.Net.SASL.Enable || secured.Net.SASL.Mechanism != sarama.SASLTypeOAuth {
+		t.Errorf("expected the source to authenticate with OAUTHBEARER, got %+v", secured.Net.SASL)
+	}
+
+	if inherited, err := mirrorConfig(config.MirrorConfig{}, monitored); err != nil || inherited != monitored {
+		t.Errorf("expected a source without SASL settings to share the monitored cluster's, got %v", err)
+	}
+	if _, err := mirrorConfig(config.MirrorConfig{SASL: &config.SASLConfig{Mechanism: sarama.SASLTypeOAuth}}, monitored); err == nil {
+		t.Errorf("expected the source's SASL settings to be validated on their own")
+	}
+}
@@ -0,0 +1,60 @@
+package beater
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// normalizedGroupName is the name shared by groups differing only by case or surrounding whitespace
+func normalizedGroupName(group string) string {
+	return strings.ToLower(strings.TrimSpace(group))
+}
+
+// collapseGroups keeps a single group of those sharing a normalized name, also returning the groups collapsed
+// by the one kept. The group kept is the one already named as normalized if there is one, otherwise the first
+// in order, so that its offsets are still fetched under a name the brokers know
+func collapseGroups(groups []string) ([]string, map[string][]string) {
+	variants := make(map[string][]string)
+	var names []string
+	for _, group := range groups {
+		name := normalizedGroupName(group)
+		if variants[name] == nil {
+			names = append(names, name)
+		}
+		variants[name] = append(variants[name], group)
+	}
+	kept := make([]string, 0, len(names))
+	collapsed := make(map[string][]string)
+	for _, name := range names {
+		sort.Strings(variants[name])
+		keep := variants[name][0]
+		for _, group := range variants[name] {
+			if group == name {
+				keep = group
+			}
+		}
+		kept = append(kept, keep)
+		for _, group := range variants[name] {
+			if group != keep {
+				collapsed[keep] = append(collapsed[keep], group)
+			}
+		}
+	}
+	sort.Strings(kept)
+	return kept, collapsed
+}
+
+// normalizeGroups collapses the discovered groups differing only by case or surrounding whitespace when
+// normalize_group_names is set, warning about those collapsed as their offsets are no longer reported
+func (bt *Kafkabeat) normalizeGroups(groups []string) []string {
+	if !bt.normalizeGroupNames {
+		return groups
+	}
+	kept, collapsed := collapseGroups(groups)
+	for group, variants := range collapsed {
+		logp.Warn("Groups %v collide with group %s once normalized, only %s is monitored", variants, group, group)
+	}
+	return kept
+}
@@ -0,0 +1,42 @@
+package beater
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestNormalizeGroupNames(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"ListGroupsRequest": sarama.NewMockListGroupsResponse(t).
+			AddGroup("billing", "consumer").
+			AddGroup("Billing", "consumer").
+			AddGroup("Audit", "consumer"),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	restore := zookeeperGroups
+	zookeeperGroups = func() ([]string, error) { return []string{"BILLING ", "AUDIT"}, nil }
+	defer func() { zookeeperGroups = restore }()
+
+	bt := New()
+	bt.refreshGroups()
+	if expected := []string{"AUDIT", "Audit", "BILLING ", "Billing", "billing"}; !reflect.DeepEqual(bt.groups, expected) {
+		t.Errorf("expected names to be matched exactly by default, got %v", bt.groups)
+	}
+	bt.normalizeGroupNames = true
+	bt.refreshGroups()
+	// the lowercase variant is kept if there is one, otherwise the first
+	if expected := []string{"AUDIT", "billing"}; !reflect.DeepEqual(bt.groups, expected) {
+		t.Errorf("expected the case variants to collapse to one group each, got %v", bt.groups)
+	}
+	_, collapsed := collapseGroups([]string{"billing", "Billing", "BILLING ", "Audit", "AUDIT"})
+	expected := map[string][]string{"billing": {"BILLING ", "Billing"}, "AUDIT": {"Audit"}}
+	if !reflect.DeepEqual(collapsed, expected) {
+		t.Errorf("expected the collisions %v to be warned about, got %v", expected, collapsed)
+	}
+}
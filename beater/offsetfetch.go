@@ -0,0 +1,71 @@
+package beater
+
+import (
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// offsetFetchCeiling is the highest OffsetFetch version the cluster accepts, lowered each time a version is
+// rejected so that the version that works is remembered. -1 until a version was rejected
+var offsetFetchCeiling = struct {
+	sync.Mutex
+	version int16
+}{version: -1}
+
+// offsetFetchVersion returns the OffsetFetch version to request, the newest the configured Kafka version
+// supports unless the cluster rejected it
+func offsetFetchVersion() int16 {
+	// v1 is the first version reading the offsets committed to Kafka rather than Zookeeper
+	version := int16(1)
+	if client.Config().Version.IsAtLeast(sarama.V0_11_0_0) {
+		// v3 is the first version to carry the time the response was throttled for
+		version = 3
+	}
+	offsetFetchCeiling.Lock()
+	defer offsetFetchCeiling.Unlock()
+	if offsetFetchCeiling.version >= 0 && offsetFetchCeiling.version < version {
+		return offsetFetchCeiling.version
+	}
+	return version
+}
+
+// lowerOffsetFetchVersion remembers the version was rejected, false when there is no lower version to try.
+// v0 is never tried, as it reads the offsets committed to Zookeeper
+func lowerOffsetFetchVersion(rejected int16) bool {
+	if rejected <= 1 {
+		return false
+	}
+	offsetFetchCeiling.Lock()
+	defer offsetFetchCeiling.Unlock()
+	if offsetFetchCeiling.version < 0 || offsetFetchCeiling.version >= rejected {
+		logp.Warn("OffsetFetch v%v was rejected, falling back to v%v", rejected, rejected-1)
+		offsetFetchCeiling.version = rejected - 1
+	}
+	return true
+}
+
+// rejectedVersion is whether the fetch failed because the broker doesn't support the version requested,
+// as reported for the whole response or for every partition asked for
+func rejectedVersion(res *sarama.OffsetFetchResponse, err error) bool {
+	if err == sarama.ErrUnsupportedVersion {
+		return true
+	}
+	if res == nil {
+		return false
+	}
+	if res.Err == sarama.ErrUnsupportedVersion {
+		return true
+	}
+	rejected := false
+	for _, partitions := range res.Blocks {
+		for _, block := range partitions {
+			if block.Err != sarama.ErrUnsupportedVersion {
+				return false
+			}
+			rejected = true
+		}
+	}
+	return rejected
+}
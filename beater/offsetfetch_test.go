@@ -0,0 +1,79 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+// offsetFetchVersions are the versions of the OffsetFetch requests the broker was sent
+func offsetFetchVersions(broker *sarama.MockBroker) []int16 {
+	var versions []int16
+	for _, exchange := range broker.History() {
+		if request, ok := exchange.Request.(*sarama.OffsetFetchRequest); ok {
+			versions = append(versions, request.Version)
+		}
+	}
+	return versions
+}
+
+// rejectingOffsetFetch is the response of a broker that predates the version, failing every partition asked for
+func rejectingOffsetFetch(version int16) sarama.MockResponse {
+	rejected := &sarama.OffsetFetchResponse{Version: version}
+	rejected.AddBlock("orders", 0, &sarama.OffsetFetchResponseBlock{Offset: -1, Err: sarama.ErrUnsupportedVersion})
+	return sarama.NewMockWrapper(rejected)
+}
+
+func TestOffsetFetchVersionFallback(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest": sarama.NewMockSequence(
+			rejectingOffsetFetch(3),
+			sarama.NewMockOffsetFetchResponse(t).SetOffset("billing", "orders", 0, 7, "", sarama.ErrNoError),
+		),
+	})
+	conf := sarama.NewConfig()
+	conf.Version = sarama.V0_11_0_0
+	connectTestClient(t, broker, conf)
+	defer client.Close()
+	defer func() { offsetFetchCeiling.version = -1 }()
+
+	offsets, err := getConsumerOffsets("billing", "orders", []int32{0})
+	if err != nil || offsets[0] != 7 {
+		t.Fatalf("expected the offset to be fetched with v2, got %v %v", offsets, err)
+	}
+	if versions := offsetFetchVersions(broker); len(versions) != 2 || versions[0] != 3 || versions[1] != 2 {
+		t.Errorf("expected v3 to be tried then v2, got %v", versions)
+	}
+	if version := offsetFetchVersion(); version != 2 {
+		t.Errorf("expected v2 to be remembered, got v%v", version)
+	}
+}
+
+func TestOffsetFetchNoZookeeperFallback(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest": rejectingOffsetFetch(1),
+	})
+	conf := sarama.NewConfig()
+	conf.Version = sarama.V0_10_0_0
+	connectTestClient(t, broker, conf)
+	defer client.Close()
+	defer func() { offsetFetchCeiling.version = -1 }()
+
+	// v0 would read the offsets committed to Zookeeper as if committed to Kafka
+	if offsets, err := getConsumerOffsets("billing", "orders", []int32{0}); err == nil {
+		t.Errorf("expected the rejected fetch to fail, got %v", offsets)
+	}
+	if versions := offsetFetchVersions(broker); len(versions) != 1 || versions[0] != 1 {
+		t.Errorf("expected only v1 to be tried, got %v", versions)
+	}
+}
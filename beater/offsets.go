@@ -0,0 +1,162 @@
+package beater
+
+import (
+	"fmt"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// stores a committed offset can be found in
+const (
+	kafkaStore     = "kafka"
+	zookeeperStore = "zookeeper"
+)
+
+// OffsetStore is a backend consumers commit their offsets to
+type OffsetStore interface {
+	// FetchOffsets returns the offsets the group committed for the partitions of the topic,
+	// leaving out the partitions it has not committed to
+	FetchOffsets(group, topic string, partitions []int32) (map[int32]int64, error)
+}
+
+var offsetStoreRegistry = map[string]OffsetStore{
+	kafkaStore:     kafkaOffsetStore{},
+	zookeeperStore: zookeeperOffsetStore{},
+}
+
+// offsetStores names the stores offsets are read from, a partition's offset being taken from the first holding one.
+// Kafka comes before Zookeeper as that is where migrated consumers commit to
+var offsetStores = []string{kafkaStore, zookeeperStore}
+
+// RegisterOffsetStore makes a custom offset store available to select by name in offset_stores
+func RegisterOffsetStore(name string, store OffsetStore) {
+	offsetStoreRegistry[name] = store
+}
+
+// useOffsetStores selects the stores offsets are read from, in order of precedence
+func useOffsetStores(names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("No offset stores to read offsets from")
+	}
+	for _, name := range names {
+		if _, ok := offsetStoreRegistry[name]; !ok {
+			return fmt.Errorf("Unknown offset store %v", name)
+		}
+	}
+	offsetStores = names
+	return nil
+}
+
+// duplicateGroups holds the discovered groups found in both Zookeeper and Kafka, which may be committing
+// divergent offsets to each while being migrated
+var duplicateGroups = map[string]bool{}
+
+type committedOffset struct {
+	offset int64
+	store  string
+}
+
+// getCommittedOffsets merges the offsets a group committed to each of the offset stores for the partitions holding data,
+// preferring the earlier stores for partitions found in several
+func getCommittedOffsets(group string, topic string, pids map[int32]int64) (map[int32]committedOffset, error) {
+	partitions := partitionsWithData(pids)
+	offsets := make(map[int32]committedOffset)
+	if len(partitions) == 0 {
+		return offsets, nil
+	}
+	var firstErr error
+	failed := 0
+	for _, name := range offsetStores {
+		fetched, err := offsetStoreRegistry[name].FetchOffsets(group, topic, partitions)
+		if err != nil {
+			logp.Debug("kafkabeat", "Unable to fetch the offsets of group %s from %s: %v", group, name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+		}
+		for pid, offset := range fetched {
+			if found, ok := offsets[pid]; ok {
+				logp.Debug("kafkabeat", "Group %s has offsets in both %s and %s for partition %v of topic %s, using %s's", group, found.store, name, pid, topic, found.store)
+				continue
+			}
+			offsets[pid] = committedOffset{offset, name}
+		}
+	}
+	if failed == len(offsetStores) {
+		return nil, firstErr
+	}
+	return offsets, nil
+}
+
+// committedOffsetSets returns the group's merged committed offsets, or for groups found in both Zookeeper and Kafka
+// the offsets in each store apart, so that they can be compared rather than one masking the other
+func committedOffsetSets(group string, topic string, pids map[int32]int64) ([]map[int32]committedOffset, error) {
+	if !duplicateGroups[group] {
+		offsets, err := getCommittedOffsets(group, topic, pids)
+		if err != nil {
+			return nil, err
+		}
+		return []map[int32]committedOffset{offsets}, nil
+	}
+	partitions := partitionsWithData(pids)
+	var sets []map[int32]committedOffset
+	var firstErr error
+	for _, name := range offsetStores {
+		fetched, err := offsetStoreRegistry[name].FetchOffsets(group, topic, partitions)
+		if err != nil {
+			logp.Debug("kafkabeat", "Unable to fetch the offsets of group %s from %s: %v", group, name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		offsets := make(map[int32]committedOffset)
+		for pid, offset := range fetched {
+			offsets[pid] = committedOffset{offset, name}
+		}
+		sets = append(sets, offsets)
+	}
+	if sets == nil {
+		return nil, firstErr
+	}
+	return sets, nil
+}
+
+// partitionsWithData lists the partitions with a size above zero, the only ones with offsets to commit
+func partitionsWithData(pids map[int32]int64) []int32 {
+	var partitions []int32
+	for pid, size := range pids {
+		if size > 0 {
+			partitions = append(partitions, pid)
+		}
+	}
+	return partitions
+}
+
+// kafkaOffsetStore reads the offsets committed to Kafka, through the admin client when there is one
+type kafkaOffsetStore struct{}
+
+func (kafkaOffsetStore) FetchOffsets(group, topic string, partitions []int32) (map[int32]int64, error) {
+	if admin != nil {
+		return getAdminOffsets(group, topic, partitions)
+	}
+	return getConsumerOffsets(group, topic, partitions)
+}
+
+// zookeeperOffsetStore reads the offsets committed to Zookeeper
+type zookeeperOffsetStore struct{}
+
+func (zookeeperOffsetStore) FetchOffsets(group, topic string, partitions []int32) (map[int32]int64, error) {
+	offsets := make(map[int32]int64)
+	for _, pid := range partitions {
+		offset, err := zookeeperOffset(group, topic, pid)
+		if err != nil {
+			return offsets, err
+		}
+		if offset > -1 {
+			offsets[pid] = offset
+		}
+	}
+	return offsets, nil
+}
@@ -0,0 +1,145 @@
+package beater
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestProcessGroupsOffsetStore(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "modern", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "legacy", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "migrating", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("modern", "orders", 0, 7, "", sarama.ErrNoError).
+			SetOffset("migrating", "orders", 0, 9, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(map[string]map[string]map[int32]int64{
+		"legacy":    {"orders": {0: 3}},
+		"migrating": {"orders": {0: 2, 1: 4}},
+	})()
+
+	events := processGroups([]string{"modern", "legacy", "migrating"}, "orders", map[int32]int64{0: 10, 1: 20})
+	expected := map[string]map[int32]string{
+		"modern":    {0: kafkaStore},
+		"legacy":    {0: zookeeperStore},
+		"migrating": {0: kafkaStore, 1: zookeeperStore},
+	}
+	found := 0
+	for _, event := range events {
+		group := event["group"].(string)
+		pid := event["partition"].(int32)
+		store, ok := expected[group][pid]
+		if !ok {
+			t.Errorf("unexpected event for group %v partition %v", group, pid)
+			continue
+		}
+		found++
+		if event["offsetStore"] != store {
+			t.Errorf("expected group %v partition %v to be read from %v, got %v", group, pid, store, event["offsetStore"])
+		}
+	}
+	if found != 4 {
+		t.Errorf("expected 4 consumer events, got %v", events)
+	}
+}
+
+// fakeOffsetStore serves fixed offsets for every group and topic
+type fakeOffsetStore map[int32]int64
+
+func (store fakeOffsetStore) FetchOffsets(group, topic string, partitions []int32) (map[int32]int64, error) {
+	offsets := make(map[int32]int64)
+	for _, pid := range partitions {
+		if offset, ok := store[pid]; ok {
+			offsets[pid] = offset
+		}
+	}
+	return offsets, nil
+}
+
+func TestCustomOffsetStore(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "bespoke", broker),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	RegisterOffsetStore("fake", fakeOffsetStore{0: 4, 1: 18})
+	defer delete(offsetStoreRegistry, "fake")
+	restore := offsetStores
+	defer func() { offsetStores = restore }()
+	if err := useOffsetStores([]string{"fake"}); err != nil {
+		t.Fatal(err)
+	}
+
+	events := processGroups([]string{"bespoke"}, "orders", map[int32]int64{0: 10, 1: 20})
+	if len(events) != 2 {
+		t.Fatalf("expected an event per partition, got %v", events)
+	}
+	for _, event := range events {
+		expected := map[int32]int64{0: 6, 1: 2}[event["partition"].(int32)]
+		if event["lag"] != expected || event["offsetStore"] != "fake" {
+			t.Errorf("expected a lag of %v from the fake store, got %v", expected, event)
+		}
+	}
+	if err := useOffsetStores([]string{"redis"}); err == nil {
+		t.Errorf("expected an unregistered store to be rejected")
+	}
+}
+
+func TestDuplicateGroupOffsetsPerStore(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":   metadata,
+		"ListGroupsRequest": sarama.NewMockListGroupsResponse(t).AddGroup("migrating", "consumer").AddGroup("modern", "consumer"),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "migrating", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("migrating", "orders", 0, 9, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(map[string]map[string]map[int32]int64{"migrating": {"orders": {0: 2}}})()
+	restore := zookeeperGroups
+	zookeeperGroups = func() ([]string, error) { return []string{"legacy", "migrating"}, nil }
+	defer func() { zookeeperGroups = restore }()
+	defer func() { duplicateGroups = map[string]bool{} }()
+
+	bt := New()
+	bt.refreshGroups()
+	published := &capturePublisher{}
+	bt.reportDuplicateGroups(&beat.Beat{Events: published})
+	warnings := published.ofType("kafkabeat")
+	if len(warnings) != 1 || !reflect.DeepEqual(warnings[0]["duplicateGroups"], []string{"migrating"}) {
+		t.Fatalf("expected a warning listing the migrating group, got %v", warnings)
+	}
+
+	events := processGroups([]string{"migrating"}, "orders", map[int32]int64{0: 10})
+	expected := map[string]int64{kafkaStore: 9, zookeeperStore: 2}
+	if len(events) != len(expected) {
+		t.Fatalf("expected an event per store, got %v", events)
+	}
+	for _, event := range events {
+		offset, ok := expected[event["offsetStore"].(string)]
+		if !ok || event["offset"] != offset {
+			t.Errorf("expected the %v offset to be %v, got %v", event["offsetStore"], offset, event)
+		}
+		delete(expected, event["offsetStore"].(string))
+	}
+}
@@ -0,0 +1,76 @@
+package beater
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+// specs resolving to the ends of a partition rather than to a timestamp
+const (
+	newestSpec = "newest"
+	oldestSpec = "oldest"
+)
+
+// offsetSpec names the field of partition events carrying the offset a spec resolves to
+type offsetSpec struct {
+	name string
+	// sarama.OffsetNewest, sarama.OffsetOldest or a timestamp in milliseconds
+	time int64
+}
+
+// newOffsetSpecs reads the offset_specs entries, each spec being newest, oldest or an RFC 3339 timestamp.
+// Listing offsets by timestamp needs v1 of the offset request, older versions answering with the start of
+// the log segment rather than the first message at the timestamp
+func newOffsetSpecs(confs []config.OffsetSpec, version sarama.KafkaVersion) ([]offsetSpec, error) {
+	specs := make([]offsetSpec, 0, len(confs))
+	names := make(map[string]bool)
+	for _, conf := range confs {
+		if conf.Name == "" {
+			return nil, fmt.Errorf("offset_specs entries need a name")
+		}
+		if names[conf.Name] {
+			return nil, fmt.Errorf("offset_specs name %v is used more than once", conf.Name)
+		}
+		names[conf.Name] = true
+		spec := offsetSpec{name: conf.Name}
+		switch conf.Spec {
+		case newestSpec:
+			spec.time = sarama.OffsetNewest
+		case oldestSpec:
+			spec.time = sarama.OffsetOldest
+		default:
+			at, err := time.Parse(time.RFC3339, conf.Spec)
+			if err != nil {
+				return nil, fmt.Errorf("Unknown offset spec %v for %v, expected %v, %v or an RFC 3339 timestamp", conf.Spec, conf.Name, newestSpec, oldestSpec)
+			}
+			if !version.IsAtLeast(sarama.V0_10_1_0) {
+				return nil, fmt.Errorf("Kafka version %v predates listing offsets by timestamp, needed by offset spec %v", version, conf.Name)
+			}
+			spec.time = at.UnixNano() / int64(time.Millisecond)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// markOffsetSpecs adds the offset each of offset_specs resolves to to the partition's topic event. A timestamp
+// resolves to the offset of the first message at or after it, -1 when no message is that recent
+func (bt *Kafkabeat) markOffsetSpecs(events []common.MapStr) {
+	for _, event := range events {
+		topic, pid := event["topic"].(string), event["partition"].(int32)
+		for _, spec := range bt.offsetSpecs {
+			offset, err := client.GetOffset(topic, pid, spec.time)
+			if err != nil {
+				logp.Err("Unable to resolve offset spec %v for partition %v and topic %s: %v", spec.name, pid, topic, err)
+				collectionErrors.record("offset_spec", common.MapStr{"topic": topic, "partition": pid, "spec": spec.name}, err)
+				continue
+			}
+			event[spec.name] = offset
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+func TestOffsetSpecs(t *testing.T) {
+	cutover := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cutoverMs := cutover.UnixNano() / int64(time.Millisecond)
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 100).
+			SetOffset("orders", 0, sarama.OffsetOldest, 5).
+			SetOffset("orders", 0, cutoverMs, 42).
+			SetOffset("orders", 1, sarama.OffsetNewest, 200).
+			SetOffset("orders", 1, sarama.OffsetOldest, 7).
+			SetOffset("orders", 1, cutoverMs, -1),
+	})
+	conf := sarama.NewConfig()
+	conf.Version = sarama.V0_10_1_0
+	connectTestClient(t, broker, conf)
+	defer client.Close()
+
+	confs := []config.OffsetSpec{
+		{Name: "firstOffset", Spec: oldestSpec},
+		{Name: "offsetAtCutover", Spec: cutover.Format(time.RFC3339)},
+		{Name: "lastOffset", Spec: newestSpec},
+	}
+	bt := New()
+	var err error
+	bt.offsetSpecs, err = newOffsetSpecs(confs, conf.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []common.MapStr{
+		{"topic": "orders", "partition": int32(0)},
+		{"topic": "orders", "partition": int32(1)},
+	}
+	bt.markOffsetSpecs(events)
+	expected := []map[string]int64{
+		{"firstOffset": 5, "offsetAtCutover": 42, "lastOffset": 100},
+		// no message was produced after the cutover
+		{"firstOffset": 7, "offsetAtCutover": -1, "lastOffset": 200},
+	}
+	for i, fields := range expected {
+		for field, offset := range fields {
+			if events[i][field] != offset {
+				t.Errorf("expected %v of partition %v to be %v, got %v", field, i, offset, events[i][field])
+			}
+		}
+	}
+
+	if _, err := newOffsetSpecs(confs, sarama.V0_10_0_0); err == nil {
+		t.Errorf("expected a timestamp spec to be rejected for a version listing offsets by segment")
+	}
+	if _, err := newOffsetSpecs([]config.OffsetSpec{{Name: "yesterday", Spec: "1d"}}, conf.Version); err == nil {
+		t.Errorf("expected an unknown spec to be rejected")
+	}
+}
@@ -0,0 +1,243 @@
+package beater
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+const (
+	// consumerOffsetsTopic is the internal topic coordinators write the offsets groups commit to
+	consumerOffsetsTopic = "__consumer_offsets"
+	offsetsTopicStore    = "offsets_topic"
+	// how often a partition of the offsets topic delivering nothing is checked for having been read to the end
+	defaultOffsetsTopicIdle = time.Second
+)
+
+// offsetCommit is an offset a group committed, as recorded in the offsets topic
+type offsetCommit struct {
+	group     string
+	topic     string
+	partition int32
+	offset    int64
+}
+
+// offsetsTopic is an offset store built by consuming the offsets topic, as Burrow does, for when fetching
+// offsets from the coordinators is unreliable. It holds the latest offset committed for each partition
+type offsetsTopic struct {
+	consumer sarama.Consumer
+	lock     sync.Mutex
+	// group to topic to partition to the offset last committed
+	offsets map[string]map[string]map[int32]int64
+	// the high-water marks at startup of the partitions of the offsets topic not yet replayed up to them
+	behind map[int32]int64
+	idle   time.Duration
+}
+
+func newOffsetsTopic() *offsetsTopic {
+	return &offsetsTopic{offsets: make(map[string]map[string]map[int32]int64), idle: defaultOffsetsTopicIdle}
+}
+
+// consume reads every partition of the offsets topic from its oldest message until done is closed. Until
+// each partition is replayed up to its high-water mark at startup the offsets held are stale, and are not
+// served
+func (store *offsetsTopic) consume(done <-chan struct{}) error {
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return err
+	}
+	pids, err := consumer.Partitions(consumerOffsetsTopic)
+	if err != nil {
+		consumer.Close()
+		return err
+	}
+	behind := make(map[int32]int64)
+	for _, pid := range pids {
+		newest, err := client.GetOffset(consumerOffsetsTopic, pid, sarama.OffsetNewest)
+		if err != nil {
+			consumer.Close()
+			return err
+		}
+		oldest, err := client.GetOffset(consumerOffsetsTopic, pid, sarama.OffsetOldest)
+		if err != nil {
+			consumer.Close()
+			return err
+		}
+		if newest > oldest {
+			behind[pid] = newest
+		}
+	}
+	store.lock.Lock()
+	store.behind = behind
+	store.lock.Unlock()
+	store.consumer = consumer
+	for _, pid := range pids {
+		partition, err := consumer.ConsumePartition(consumerOffsetsTopic, pid, sarama.OffsetOldest)
+		if err != nil {
+			consumer.Close()
+			return err
+		}
+		go func(pid int32) {
+			defer partition.Close()
+			idle := time.NewTicker(store.idle)
+			defer idle.Stop()
+			// the high-water mark the consumer reported as of the last tick, -1 until a message is delivered
+			// and again after each
+			var reported int64 = -1
+			delivered := false
+			for {
+				select {
+				case <-done:
+					return
+				case message, ok := <-partition.Messages():
+					if !ok {
+						return
+					}
+					store.apply(message.Key, message.Value)
+					store.replayed(pid, message.Offset)
+					delivered = true
+					reported = -1
+				case <-idle.C:
+					// the partition may end in offsets no message has, such as transaction markers or
+					// offsets compacted away, so one delivering nothing for a whole tick after reporting
+					// a high-water mark is read up to it. The consumer reports the mark from when it
+					// starts and ahead of delivering the messages fetched with it, hence waiting for a
+					// first message and then the whole tick
+					highWaterMark := partition.HighWaterMarkOffset()
+					if delivered && highWaterMark == reported {
+						store.reachedEnd(pid, highWaterMark)
+					}
+					reported = highWaterMark
+				}
+			}
+		}(pid)
+	}
+	logp.Info("Consuming %v partitions of %s for committed offsets", len(pids), consumerOffsetsTopic)
+	return nil
+}
+
+// apply records the offset commit a message of the offsets topic holds, dropping the partition's offset
+// when the message is a tombstone for it. Messages about group membership are ignored
+func (store *offsetsTopic) apply(key []byte, value []byte) {
+	commit, ok, err := decodeOffsetCommit(key, value)
+	if err != nil {
+		logp.Debug("kafkabeat", "Unable to decode a message of %s: %v", consumerOffsetsTopic, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if value == nil {
+		delete(store.offsets[commit.group][commit.topic], commit.partition)
+		return
+	}
+	if store.offsets[commit.group] == nil {
+		store.offsets[commit.group] = make(map[string]map[int32]int64)
+	}
+	if store.offsets[commit.group][commit.topic] == nil {
+		store.offsets[commit.group][commit.topic] = make(map[int32]int64)
+	}
+	store.offsets[commit.group][commit.topic][commit.partition] = commit.offset
+}
+
+// replayed records the offset of the offsets topic partition read up to, the partition having caught up
+// once its startup high-water mark is reached
+func (store *offsetsTopic) replayed(pid int32, offset int64) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if highWaterMark, ok := store.behind[pid]; ok && offset+1 >= highWaterMark {
+		delete(store.behind, pid)
+	}
+}
+
+// reachedEnd records the partition of the offsets topic as read up to the high-water mark, having caught up
+// once its startup high-water mark is reached
+func (store *offsetsTopic) reachedEnd(pid int32, highWaterMark int64) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if startup, ok := store.behind[pid]; ok && highWaterMark >= startup {
+		delete(store.behind, pid)
+	}
+}
+
+func (store *offsetsTopic) FetchOffsets(group, topic string, partitions []int32) (map[int32]int64, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if len(store.behind) > 0 {
+		return nil, fmt.Errorf("%s not yet replayed up to its high-water marks, %v partitions behind", consumerOffsetsTopic, len(store.behind))
+	}
+	offsets := make(map[int32]int64)
+	for _, pid := range partitions {
+		if offset, ok := store.offsets[group][topic][pid]; ok {
+			offsets[pid] = offset
+		}
+	}
+	return offsets, nil
+}
+
+func (store *offsetsTopic) Close() error {
+	if store.consumer == nil {
+		return nil
+	}
+	return store.consumer.Close()
+}
+
+// decodeOffsetCommit decodes a message of the offsets topic, false when it is not an offset commit.
+// Keys of versions 0 and 1 are offset commits, naming the group, topic and partition. Their values
+// start with the committed offset, and are nil for tombstones
+func decodeOffsetCommit(key []byte, value []byte) (offsetCommit, bool, error) {
+	var commit offsetCommit
+	keys := bytes.NewReader(key)
+	var version int16
+	if err := binary.Read(keys, binary.BigEndian, &version); err != nil {
+		return commit, false, err
+	}
+	if version > 1 {
+		return commit, false, nil
+	}
+	var err error
+	if commit.group, err = readString(keys); err != nil {
+		return commit, false, err
+	}
+	if commit.topic, err = readString(keys); err != nil {
+		return commit, false, err
+	}
+	if err = binary.Read(keys, binary.BigEndian, &commit.partition); err != nil {
+		return commit, false, err
+	}
+	if value == nil {
+		return commit, true, nil
+	}
+	values := bytes.NewReader(value)
+	if err = binary.Read(values, binary.BigEndian, &version); err != nil {
+		return commit, false, err
+	}
+	if version < 0 || version > 3 {
+		return commit, false, fmt.Errorf("Unknown offset commit value version %v", version)
+	}
+	if err = binary.Read(values, binary.BigEndian, &commit.offset); err != nil {
+		return commit, false, err
+	}
+	return commit, true, nil
+}
+
+// readString reads a string prefixed with its int16 length
+func readString(reader *bytes.Reader) (string, error) {
+	var length int16
+	if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	if length < 0 || int(length) > reader.Len() {
+		return "", fmt.Errorf("Invalid string length %v", length)
+	}
+	buf := make([]byte, length)
+	reader.Read(buf)
+	return string(buf), nil
+}
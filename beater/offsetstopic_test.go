@@ -0,0 +1,173 @@
+package beater
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// encodeOffsetCommit builds the key and version 1 value of an offset commit in the offsets topic
+func encodeOffsetCommit(group string, topic string, partition int32, offset int64) ([]byte, []byte) {
+	key := new(bytes.Buffer)
+	binary.Write(key, binary.BigEndian, int16(1))
+	for _, s := range []string{group, topic} {
+		binary.Write(key, binary.BigEndian, int16(len(s)))
+		key.WriteString(s)
+	}
+	binary.Write(key, binary.BigEndian, partition)
+	value := new(bytes.Buffer)
+	binary.Write(value, binary.BigEndian, int16(1))
+	binary.Write(value, binary.BigEndian, offset)
+	// empty metadata, then the commit and expiry timestamps
+	binary.Write(value, binary.BigEndian, int16(0))
+	binary.Write(value, binary.BigEndian, int64(1500000000000))
+	binary.Write(value, binary.BigEndian, int64(1500086400000))
+	return key.Bytes(), value.Bytes()
+}
+
+func TestDecodeOffsetCommit(t *testing.T) {
+	key, value := encodeOffsetCommit("billing", "orders", 3, 42)
+	commit, ok, err := decodeOffsetCommit(key, value)
+	if err != nil || !ok {
+		t.Fatalf("expected an offset commit, got %v %v", ok, err)
+	}
+	expected := offsetCommit{group: "billing", topic: "orders", partition: 3, offset: 42}
+	if commit != expected {
+		t.Errorf("expected %+v, got %+v", expected, commit)
+	}
+
+	// group metadata messages are keyed by version 2
+	metadata := new(bytes.Buffer)
+	binary.Write(metadata, binary.BigEndian, int16(2))
+	binary.Write(metadata, binary.BigEndian, int16(len("billing")))
+	metadata.WriteString("billing")
+	if _, ok, err := decodeOffsetCommit(metadata.Bytes(), []byte{0, 2}); ok || err != nil {
+		t.Errorf("expected group metadata to be skipped, got %v %v", ok, err)
+	}
+}
+
+func TestOffsetsTopicStore(t *testing.T) {
+	store := newOffsetsTopic()
+	key, value := encodeOffsetCommit("billing", "orders", 0, 5)
+	store.apply(key, value)
+	key, value = encodeOffsetCommit("billing", "orders", 0, 9)
+	store.apply(key, value)
+	key, value = encodeOffsetCommit("billing", "orders", 1, 7)
+	store.apply(key, value)
+	// a tombstone drops the partition's offset
+	store.apply(key, nil)
+
+	offsets, err := store.FetchOffsets("billing", "orders", []int32{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offsets) != 1 || offsets[0] != 9 {
+		t.Errorf("expected the latest offset of partition 0 only, got %v", offsets)
+	}
+}
+
+func TestOffsetsTopicBehind(t *testing.T) {
+	store := newOffsetsTopic()
+	store.behind = map[int32]int64{0: 2}
+	key, value := encodeOffsetCommit("billing", "orders", 0, 5)
+	store.apply(key, value)
+	store.replayed(0, 0)
+	if offsets, err := store.FetchOffsets("billing", "orders", []int32{0}); err == nil {
+		t.Errorf("expected no offsets until replayed to the high-water mark, got %v", offsets)
+	}
+	key, value = encodeOffsetCommit("billing", "orders", 0, 9)
+	store.apply(key, value)
+	store.replayed(0, 1)
+	offsets, err := store.FetchOffsets("billing", "orders", []int32{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offsets[0] != 9 {
+		t.Errorf("expected the offset last committed, got %v", offsets)
+	}
+}
+
+func TestOffsetsTopicConsume(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{consumerOffsetsTopic: 1})
+	defer broker.Close()
+	first, firstValue := encodeOffsetCommit("billing", "orders", 0, 5)
+	second, secondValue := encodeOffsetCommit("billing", "orders", 0, 9)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset(consumerOffsetsTopic, 0, sarama.OffsetOldest, 0).
+			SetOffset(consumerOffsetsTopic, 0, sarama.OffsetNewest, 2),
+		"FetchRequest": sarama.NewMockFetchResponse(t, 1).
+			SetMessageWithKey(consumerOffsetsTopic, 0, 0, sarama.ByteEncoder(first), sarama.ByteEncoder(firstValue)).
+			SetMessageWithKey(consumerOffsetsTopic, 0, 1, sarama.ByteEncoder(second), sarama.ByteEncoder(secondValue)).
+			SetHighWaterMark(consumerOffsetsTopic, 0, 2),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+
+	store := newOffsetsTopic()
+	done := make(chan struct{})
+	defer close(done)
+	if err := store.consume(done); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		offsets, err := store.FetchOffsets("billing", "orders", []int32{0})
+		if err == nil {
+			if offsets[0] != 9 {
+				t.Errorf("expected the offset committed last once caught up, got %v", offsets)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the offsets topic to be replayed, got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOffsetsTopicEndsInGap(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{consumerOffsetsTopic: 1})
+	defer broker.Close()
+	key, value := encodeOffsetCommit("billing", "orders", 0, 5)
+	// offset 1, the last before the high-water mark, holds no message, as for a transaction marker
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset(consumerOffsetsTopic, 0, sarama.OffsetOldest, 0).
+			SetOffset(consumerOffsetsTopic, 0, sarama.OffsetNewest, 2),
+		"FetchRequest": sarama.NewMockFetchResponse(t, 1).
+			SetMessageWithKey(consumerOffsetsTopic, 0, 0, sarama.ByteEncoder(key), sarama.ByteEncoder(value)).
+			SetHighWaterMark(consumerOffsetsTopic, 0, 2),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+
+	store := newOffsetsTopic()
+	store.idle = 50 * time.Millisecond
+	done := make(chan struct{})
+	defer close(done)
+	if err := store.consume(done); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		offsets, err := store.FetchOffsets("billing", "orders", []int32{0})
+		if err == nil {
+			if offsets[0] != 5 {
+				t.Errorf("expected the offset committed last once caught up, got %v", offsets)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the offsets topic to be caught up despite the gap, got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,60 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// ownerHistory is the member a partition was last seen assigned to and when it changed hands
+type ownerHistory struct {
+	member  interface{}
+	since   time.Time
+	changes []time.Time
+}
+
+// markOwnerChanges flags consumer events whose partition moved to another member of the group since the
+// last tick, as in rebalance storms
+func (bt *Kafkabeat) markOwnerChanges(events []common.MapStr) {
+	bt.trackOwners(events, time.Now())
+}
+
+// trackOwners flags the events of partitions assigned to another member than the one last seen with
+// ownerChanged and the previousOwner, and adds how often the partition changed hands over the last minute,
+// or the last five periods when those are longer, as ownerChangesPerMinute. Partitions with no owner while
+// the group rebalances are left for the next tick to compare
+func (bt *Kafkabeat) trackOwners(events []common.MapStr, now time.Time) {
+	window := time.Minute
+	if 5*bt.period > window {
+		window = 5 * bt.period
+	}
+	for _, event := range events {
+		member, ok := event["memberId"]
+		if !ok {
+			continue
+		}
+		key := stateKey("owners", seriesKey(event))
+		previous, ok := bt.state.Get(key)
+		if !ok {
+			bt.state.Put(key, &ownerHistory{member: member, since: now})
+			continue
+		}
+		history := previous.(*ownerHistory)
+		if member != history.member {
+			event.Update(common.MapStr{"ownerChanged": true, "previousOwner": history.member})
+			history.member = member
+			history.changes = append(history.changes, now)
+		}
+		for len(history.changes) > 0 && !history.changes[0].After(now.Add(-window)) {
+			history.changes = history.changes[1:]
+		}
+		bt.state.Put(key, history)
+		observed := now.Sub(history.since)
+		if observed > window {
+			observed = window
+		}
+		if observed > 0 {
+			event["ownerChangesPerMinute"] = float64(len(history.changes)) / observed.Minutes()
+		}
+	}
+}
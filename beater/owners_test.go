@@ -0,0 +1,37 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestOwnerChanges(t *testing.T) {
+	bt := New()
+	bt.period = 10 * time.Second
+	start := time.Now()
+	consumer := func(member string) common.MapStr {
+		return common.MapStr{"type": "consumer", "group": "billing", "topic": "orders", "partition": int32(0), "offset": int64(0), "memberId": member}
+	}
+
+	var events []common.MapStr
+	// the partition flips between two members on 3 of the 6 ticks following the first
+	for tick, member := range []string{"consumer-1", "consumer-1", "consumer-2", "consumer-2", "consumer-1", "consumer-2", "consumer-2"} {
+		event := consumer(member)
+		bt.trackOwners([]common.MapStr{event}, start.Add(time.Duration(tick)*bt.period))
+		events = append(events, event)
+	}
+	for tick, event := range events {
+		changed := tick == 2 || tick == 4 || tick == 5
+		if (event["ownerChanged"] == true) != changed {
+			t.Errorf("expected ownerChanged %v on tick %v, got %v", changed, tick, event)
+		}
+	}
+	if events[2]["previousOwner"] != "consumer-1" || events[4]["previousOwner"] != "consumer-2" {
+		t.Errorf("expected the previous owners to be reported, got %v and %v", events[2], events[4])
+	}
+	if last := events[len(events)-1]; last["ownerChangesPerMinute"] != 3.0 {
+		t.Errorf("expected 3 owner changes per minute, got %v", last["ownerChangesPerMinute"])
+	}
+}
@@ -0,0 +1,67 @@
+package beater
+
+import (
+	"fmt"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/publisher"
+)
+
+// pipelineRegistry holds the clients event types can be routed to by name instead of the beat's own
+var pipelineRegistry = map[string]publisher.Client{}
+
+// RegisterPipeline makes a publisher client available to route event types to by name in pipelines
+func RegisterPipeline(name string, client publisher.Client) {
+	pipelineRegistry[name] = client
+}
+
+// usePipelines binds event types to the registered pipelines named for them
+func (bt *Kafkabeat) usePipelines(bindings map[string]string) error {
+	bt.pipelines = make(map[string]publisher.Client)
+	for eventType, name := range bindings {
+		client, ok := pipelineRegistry[name]
+		if !ok {
+			return fmt.Errorf("Unknown pipeline %v for %v events", name, eventType)
+		}
+		bt.pipelines[eventType] = client
+	}
+	return nil
+}
+
+// clientFor returns the client events of the type are published through, the beat's own unless bound to a pipeline,
+// writing them to stdout too or instead when stdout_output is set
+func (bt *Kafkabeat) clientFor(b *beat.Beat, eventType interface{}) publisher.Client {
+	if name, ok := eventType.(string); ok {
+		if client, ok := bt.pipelines[name]; ok {
+			return bt.toStdout(client)
+		}
+	}
+	return bt.toStdout(b.Events)
+}
+
+// routed is the events published through a client
+type routed struct {
+	client publisher.Client
+	events []common.MapStr
+}
+
+// route splits the events by the client each is published through, keeping their order
+func (bt *Kafkabeat) route(b *beat.Beat, events []common.MapStr) []*routed {
+	var batches []*routed
+	for _, event := range events {
+		client := bt.clientFor(b, event["type"])
+		var batch *routed
+		for _, candidate := range batches {
+			if candidate.client == client {
+				batch = candidate
+			}
+		}
+		if batch == nil {
+			batch = &routed{client: client}
+			batches = append(batches, batch)
+		}
+		batch.events = append(batch.events, event)
+	}
+	return batches
+}
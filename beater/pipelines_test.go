@@ -0,0 +1,34 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestPipelinesByEventType(t *testing.T) {
+	lag := &capturePublisher{}
+	RegisterPipeline("lag", lag)
+	defer delete(pipelineRegistry, "lag")
+	bt := New()
+	if err := bt.usePipelines(map[string]string{"consumer": "lag", "group_topic": "lag"}); err != nil {
+		t.Fatal(err)
+	}
+	defaults := &capturePublisher{}
+	bt.publish(&beat.Beat{Events: defaults}, []common.MapStr{
+		{"type": "topic", "topic": "orders", "partition": int32(0), "size": int64(10)},
+		{"type": "consumer", "topic": "orders", "group": "billing", "partition": int32(0), "offset": int64(5)},
+		{"type": "group_topic", "topic": "orders", "group": "billing", "partitionsWithOffsets": 1},
+	})
+
+	if len(lag.events) != 2 || len(lag.ofType("consumer")) != 1 || len(lag.ofType("group_topic")) != 1 {
+		t.Errorf("expected consumer and group_topic events in the lag pipeline, got %v", lag.events)
+	}
+	if len(defaults.events) != 1 || len(defaults.ofType("topic")) != 1 {
+		t.Errorf("expected only topic events through the beat's own pipeline, got %v", defaults.events)
+	}
+	if err := bt.usePipelines(map[string]string{"topic": "retention"}); err == nil {
+		t.Errorf("expected an unregistered pipeline to be rejected")
+	}
+}
@@ -0,0 +1,139 @@
+package beater
+
+import (
+	"os"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+const defaultMaxSuppressTicks = 10
+
+const (
+	defaultMetricsetName   = "kafkabeat"
+	defaultMetricsetModule = "kafka"
+)
+
+// SchemaVersion is published on every event as schemaVersion, bumped whenever the structure of the events
+// changes so that parsers can tell which they are handling
+const SchemaVersion = 1
+
+// emitted is what was last published for an event key and for how many ticks it has been suppressed since
+type emitted struct {
+	values     map[string]interface{}
+	suppressed int
+}
+
+func (bt *Kafkabeat) publish(b *beat.Beat, events []common.MapStr) {
+	if bt.pushGateway != nil {
+		bt.pushGateway.add(events)
+	}
+	if bt.suppressUnchanged {
+		events = bt.suppress(events)
+	}
+	if len(events) > 0 {
+		bt.enrich(events)
+		bt.send(b, events)
+		bt.batch.add(events)
+		collectionStats.Add("events", int64(len(events)))
+		logp.Info("%v Events sent", len(events))
+	}
+}
+
+// enrich adds the fields every published event carries
+func (bt *Kafkabeat) enrich(events []common.MapStr) {
+	for _, event := range events {
+		if bt.warmingUp() {
+			dropDeltaFields(event)
+		}
+		if bt.groupNamePattern != nil {
+			bt.addGroupNameFields(event)
+		}
+		bt.addMetricset(event)
+		bt.addMetricUnit(event)
+		bt.addClusterId(event)
+		bt.addIndex(event)
+		if bt.timestampAlignment == periodAlignment {
+			bt.alignTimestamp(event)
+		}
+		if bt.collector != "" {
+			event["collector"] = bt.collector
+		}
+		// the collection pass the event belongs to, as the timestamps of a pass differ and clocks skew
+		event["tickSeq"] = bt.ticks
+		event["schemaVersion"] = SchemaVersion
+	}
+}
+
+// send publishes the events through the clients they are routed to
+func (bt *Kafkabeat) send(b *beat.Beat, events []common.MapStr) {
+	for _, batch := range bt.route(b, events) {
+		batch.client.PublishEvents(batch.events)
+	}
+}
+
+// addMetricset names the metricset the event belongs to the way Metricbeat modules do
+func (bt *Kafkabeat) addMetricset(event common.MapStr) {
+	event["metricset"] = common.MapStr{"name": bt.metricsetName, "module": bt.metricsetModule}
+}
+
+// collectorName is the configured name of the collector, falling back to the hostname
+func collectorName(configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+	return os.Hostname()
+}
+
+// occurrenceTypes are the types of events reporting something that happened, each published however alike
+var occurrenceTypes = map[interface{}]bool{"error": true, "broker_added": true, "broker_removed": true, "isr_change": true}
+
+// suppress drops events whose numeric fields are identical to those last published for the same key,
+// until maxSuppressTicks consecutive ticks have been dropped and the event is published again as a refresh
+func (bt *Kafkabeat) suppress(events []common.MapStr) []common.MapStr {
+	var changed []common.MapStr
+	for _, event := range events {
+		key := stateKey("emitted", event["type"], seriesKey(event))
+		values := numericFields(event)
+		if len(values) == 0 || occurrenceTypes[event["type"]] || event["type"] == "kafkabeat" {
+			// nothing to compare, as for events nesting their metrics, each an occurrence rather than a series,
+			// or the beat's own status events, which share a type whatever they report
+			changed = append(changed, event)
+			continue
+		}
+		if previous, ok := bt.state.Get(key); ok {
+			last := previous.(*emitted)
+			if last.suppressed < bt.maxSuppressTicks && sameValues(last.values, values) {
+				last.suppressed++
+				continue
+			}
+		}
+		bt.state.Put(key, &emitted{values: values})
+		changed = append(changed, event)
+	}
+	return changed
+}
+
+func numericFields(event common.MapStr) map[string]interface{} {
+	values := make(map[string]interface{})
+	for field, value := range event {
+		switch value.(type) {
+		case int, int32, int64, float64:
+			values[field] = value
+		}
+	}
+	return values
+}
+
+func sameValues(previous map[string]interface{}, current map[string]interface{}) bool {
+	if len(previous) != len(current) {
+		return false
+	}
+	for field, value := range current {
+		if previous[field] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package beater
+
+import (
+	"os"
+	"testing"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestSuppressUnchanged(t *testing.T) {
+	bt := New()
+	bt.suppressUnchanged = true
+	bt.maxSuppressTicks = 2
+
+	var published []int
+	for tick := 1; tick <= 6; tick++ {
+		lag := int64(5)
+		if tick >= 5 {
+			lag = 6
+		}
+		event := common.MapStr{"type": "consumer", "topic": "orders", "group": "billing", "partition": int32(0), "lag": lag}
+		if len(bt.suppress([]common.MapStr{event})) == 1 {
+			published = append(published, tick)
+		}
+	}
+	// tick 4 is the forced refresh after two suppressed ticks, tick 5 a changed value
+	expected := []int{1, 4, 5}
+	if len(published) != len(expected) {
+		t.Fatalf("expected ticks %v to be published, got %v", expected, published)
+	}
+	for i := range expected {
+		if published[i] != expected[i] {
+			t.Fatalf("expected ticks %v to be published, got %v", expected, published)
+		}
+	}
+}
+
+func TestSuppressPerBroker(t *testing.T) {
+	bt := New()
+	bt.suppressUnchanged = true
+	bt.maxSuppressTicks = 5
+
+	brokers := func() []common.MapStr {
+		return []common.MapStr{
+			{"type": "broker_latency", "brokerId": int32(1), "requests": 4, "p50Ms": 3.0, "maxMs": 5.0},
+			{"type": "broker_latency", "brokerId": int32(2), "requests": 4, "p50Ms": 8.0, "maxMs": 9.0},
+			{"type": "kafkabeat", "groupsCovered": 2},
+			{"type": "kafkabeat", "tickOverrun": true, "tickDurationMs": int64(1200)},
+		}
+	}
+	if published := bt.suppress(brokers()); len(published) != 4 {
+		t.Fatalf("expected every event of the first tick to be published, got %v", published)
+	}
+	published := bt.suppress(brokers())
+	if len(published) != 2 {
+		t.Fatalf("expected only the status events to be published again, got %v", published)
+	}
+	for _, event := range published {
+		if event["type"] != "kafkabeat" {
+			t.Errorf("expected the unchanged latency of each broker to be suppressed, got %v", event)
+		}
+	}
+}
+
+func TestMetricsetFields(t *testing.T) {
+	bt := New()
+	bt.metricsetName = "lag"
+	published := &capturePublisher{}
+	bt.publish(&beat.Beat{Events: published}, []common.MapStr{
+		{"type": "topic", "topic": "orders", "partition": int32(0), "size": int64(10)},
+		{"type": "consumer", "topic": "orders", "group": "billing", "partition": int32(0), "offset": int64(5)},
+	})
+	if len(published.events) != 2 {
+		t.Fatalf("expected both events to be published, got %v", published.events)
+	}
+	for _, event := range published.events {
+		metricset := event["metricset"].(common.MapStr)
+		if metricset["name"] != "lag" || metricset["module"] != defaultMetricsetModule {
+			t.Errorf("expected the %v event in metricset lag of module kafka, got %v", event["type"], metricset)
+		}
+	}
+}
+
+func TestCollectorField(t *testing.T) {
+	bt := New()
+	var err error
+	bt.collector, err = collectorName("dc1-collector")
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := &capturePublisher{}
+	bt.publish(&beat.Beat{Events: published}, []common.MapStr{
+		{"type": "topic", "topic": "orders", "partition": int32(0), "size": int64(10)},
+		{"type": "kafkabeat", "unknownGroups": []string{"billing"}},
+	})
+	for _, event := range published.events {
+		if event["collector"] != "dc1-collector" {
+			t.Errorf("expected the %v event to name the configured collector, got %v", event["type"], event["collector"])
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collector, err := collectorName(""); err != nil || collector != hostname {
+		t.Errorf("expected the collector to fall back to the hostname %v, got %v, %v", hostname, collector, err)
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	bt := New()
+	published := &capturePublisher{}
+	bt.publish(&beat.Beat{Events: published}, []common.MapStr{
+		{"type": "topic", "topic": "orders", "partition": int32(0), "size": int64(10)},
+		{"type": "error", "operation": "coordinator", "error": "kafka server: Not authorized to access group"},
+	})
+	if len(published.events) != 2 {
+		t.Fatalf("expected both events to be published, got %v", published.events)
+	}
+	for _, event := range published.events {
+		if event["schemaVersion"] != SchemaVersion {
+			t.Errorf("expected the %v event to carry schema version %v, got %v", event["type"], SchemaVersion, event["schemaVersion"])
+		}
+	}
+}
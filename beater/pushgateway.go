@@ -0,0 +1,132 @@
+package beater
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+// gauges the events of a tick are exposed as, by event type and the field holding the value
+var pushedGauges = []struct {
+	eventType string
+	field     string
+	name      string
+	help      string
+}{
+	{"topic", "size", "kafka_log_size", "Size of the partition at the offset basis"},
+	{"consumer", "offset", "kafka_consumer_offset", "Offset the group committed for the partition"},
+	{"consumer", "lag", "kafka_consumer_lag", "Messages of the partition the group has yet to consume"},
+}
+
+// pushGateway pushes the gauges of every tick to a Prometheus Pushgateway
+type pushGateway struct {
+	url    string
+	client *http.Client
+	// the value of each series seen since the last push, by gauge name then series. A series seen again,
+	// as when a pass is retried, keeps its last value, the gateway rejecting a push repeating a series
+	samples map[string]map[string]interface{}
+}
+
+func newPushGateway(conf config.PushGatewayConfig, collector string) (*pushGateway, error) {
+	gateway, err := url.Parse(conf.URL)
+	if err != nil {
+		return nil, err
+	}
+	if gateway.Scheme == "" || gateway.Host == "" {
+		return nil, fmt.Errorf("pushgateway url %v must name a scheme and host", conf.URL)
+	}
+	job := conf.Job
+	if job == "" {
+		job = "kafkabeat"
+	}
+	instance := conf.Instance
+	if instance == "" {
+		instance = collector
+	}
+	return &pushGateway{
+		url:     fmt.Sprintf("%s/metrics/job/%s/instance/%s", strings.TrimRight(conf.URL, "/"), url.PathEscape(job), url.PathEscape(instance)),
+		client:  &http.Client{Timeout: 10 * time.Second},
+		samples: make(map[string]map[string]interface{}),
+	}, nil
+}
+
+// add records the gauges of the events
+func (p *pushGateway) add(events []common.MapStr) {
+	for _, event := range events {
+		for _, gauge := range pushedGauges {
+			if event["type"] != gauge.eventType {
+				continue
+			}
+			value, ok := event[gauge.field]
+			if !ok || value == unknownOffset {
+				continue
+			}
+			var labels []string
+			// a group found in both stores has an event per store, which are told apart by the store
+			for _, label := range []string{"group", "topic", "partition", "offsetStore"} {
+				if labelValue, ok := event[label]; ok {
+					labels = append(labels, fmt.Sprintf("%s=\"%s\"", label, escapeLabel(fmt.Sprint(labelValue))))
+				}
+			}
+			if p.samples[gauge.name] == nil {
+				p.samples[gauge.name] = make(map[string]interface{})
+			}
+			p.samples[gauge.name][fmt.Sprintf("%s{%s}", gauge.name, strings.Join(labels, ","))] = value
+		}
+	}
+}
+
+// payload renders the recorded gauges in the Prometheus text exposition format
+func (p *pushGateway) payload() []byte {
+	var buffer bytes.Buffer
+	for _, gauge := range pushedGauges {
+		samples := p.samples[gauge.name]
+		if len(samples) == 0 {
+			continue
+		}
+		series := make([]string, 0, len(samples))
+		for name := range samples {
+			series = append(series, name)
+		}
+		sort.Strings(series)
+		fmt.Fprintf(&buffer, "# HELP %s %s\n# TYPE %s gauge\n", gauge.name, gauge.help, gauge.name)
+		for _, name := range series {
+			fmt.Fprintf(&buffer, "%s %v\n", name, samples[name])
+		}
+	}
+	return buffer.Bytes()
+}
+
+// push replaces the metrics of the job and instance on the gateway with the gauges recorded since the
+// last push. Failing to push is logged, the next tick pushing afresh
+func (p *pushGateway) push() {
+	body := p.payload()
+	p.samples = make(map[string]map[string]interface{})
+	request, err := http.NewRequest("PUT", p.url, bytes.NewReader(body))
+	if err != nil {
+		logp.Err("Unable to build the push to %s: %v", p.url, err)
+		return
+	}
+	request.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	res, err := p.client.Do(request)
+	if err != nil {
+		logp.Err("Unable to push metrics to %s: %v", p.url, err)
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		logp.Err("Pushing metrics to %s failed with status %v", p.url, res.Status)
+	}
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,97 @@
+package beater
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+func TestPushGateway(t *testing.T) {
+	var method, path, body string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := ioutil.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	pusher, err := newPushGateway(config.PushGatewayConfig{URL: gateway.URL}, "collector-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pusher.add([]common.MapStr{
+		{"type": "topic", "topic": "orders", "partition": int32(1), "size": int64(20)},
+		{"type": "topic", "topic": "orders", "partition": int32(0), "size": int64(10)},
+		{"type": "consumer", "group": "billing", "topic": "orders", "partition": int32(0), "offset": int64(4), "lag": int64(6)},
+		{"type": "kafkabeat", "tickMs": int64(1200)},
+	})
+	pusher.push()
+
+	if method != "PUT" || path != "/metrics/job/kafkabeat/instance/collector-1" {
+		t.Errorf("expected a PUT to the job and instance, got %v %v", method, path)
+	}
+	expected := `# HELP kafka_log_size Size of the partition at the offset basis
+# TYPE kafka_log_size gauge
+kafka_log_size{topic="orders",partition="0"} 10
+kafka_log_size{topic="orders",partition="1"} 20
+# HELP kafka_consumer_offset Offset the group committed for the partition
+# TYPE kafka_consumer_offset gauge
+kafka_consumer_offset{group="billing",topic="orders",partition="0"} 4
+# HELP kafka_consumer_lag Messages of the partition the group has yet to consume
+# TYPE kafka_consumer_lag gauge
+kafka_consumer_lag{group="billing",topic="orders",partition="0"} 6
+`
+	if body != expected {
+		t.Errorf("expected the payload\n%v\ngot\n%v", expected, body)
+	}
+
+	// gauges are pushed afresh each tick, and a gateway that is down doesn't fail the push
+	gateway.Close()
+	pusher.push()
+	if len(pusher.samples) != 0 {
+		t.Errorf("expected the pushed samples to be cleared, got %v", pusher.samples)
+	}
+	if _, err := newPushGateway(config.PushGatewayConfig{URL: "localhost"}, "collector-1"); err == nil {
+		t.Errorf("expected a url without a scheme to be rejected")
+	}
+}
+
+func TestPushGatewayDuplicateGroup(t *testing.T) {
+	pusher, err := newPushGateway(config.PushGatewayConfig{URL: "http://localhost:9091"}, "collector-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pusher.add([]common.MapStr{
+		{"type": "consumer", "group": "billing", "topic": "orders", "partition": int32(0), "offset": int64(4), "offsetStore": kafkaStore},
+		{"type": "consumer", "group": "billing", "topic": "orders", "partition": int32(0), "offset": int64(2), "offsetStore": zookeeperStore},
+	})
+	expected := `# HELP kafka_consumer_offset Offset the group committed for the partition
+# TYPE kafka_consumer_offset gauge
+kafka_consumer_offset{group="billing",topic="orders",partition="0",offsetStore="kafka"} 4
+kafka_consumer_offset{group="billing",topic="orders",partition="0",offsetStore="zookeeper"} 2
+`
+	if body := string(pusher.payload()); body != expected {
+		t.Errorf("expected the series of each store apart\n%v\ngot\n%v", expected, body)
+	}
+}
+
+func TestPushGatewaySeriesSeenTwice(t *testing.T) {
+	pusher, err := newPushGateway(config.PushGatewayConfig{URL: "http://localhost:9091"}, "collector-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// as when a pass is retried
+	pusher.add([]common.MapStr{{"type": "consumer", "group": "billing", "topic": "orders", "partition": int32(0), "offset": int64(4)}})
+	pusher.add([]common.MapStr{{"type": "consumer", "group": "billing", "topic": "orders", "partition": int32(0), "offset": int64(5)}})
+	expected := `# HELP kafka_consumer_offset Offset the group committed for the partition
+# TYPE kafka_consumer_offset gauge
+kafka_consumer_offset{group="billing",topic="orders",partition="0"} 5
+`
+	if body := string(pusher.payload()); body != expected {
+		t.Errorf("expected the series once with its last value\n%v\ngot\n%v", expected, body)
+	}
+}
@@ -0,0 +1,48 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+const defaultOffsetResetGrace = 5 * time.Minute
+
+// resetHistory is the committed offset last seen for a partition and when it last moved backwards
+type resetHistory struct {
+	offset int64
+	reset  time.Time
+}
+
+// markOffsetResets flags consumer events whose committed offset moved backwards since the last tick,
+// as when an operator resets a group's offsets
+func (bt *Kafkabeat) markOffsetResets(events []common.MapStr) {
+	bt.trackResets(events, time.Now())
+}
+
+// trackResets flags consumer events whose committed offset is behind the one last seen with offsetReset and
+// the change in offsetResetDelta. For offset_reset_grace after a reset the alert fields are left out, as the
+// lag jumps with the offset
+func (bt *Kafkabeat) trackResets(events []common.MapStr, now time.Time) {
+	for _, event := range events {
+		key := stateKey("resets", seriesKey(event))
+		offset := event["offset"].(int64)
+		previous, ok := bt.state.Get(key)
+		if !ok {
+			bt.state.Put(key, &resetHistory{offset: offset})
+			continue
+		}
+		history := previous.(*resetHistory)
+		if offset < history.offset {
+			event.Update(common.MapStr{"offsetReset": true, "offsetResetDelta": offset - history.offset})
+			history.reset = now
+		}
+		history.offset = offset
+		bt.state.Put(key, history)
+		if !history.reset.IsZero() && now.Sub(history.reset) < bt.offsetResetGrace {
+			delete(event, "overThreshold")
+			delete(event, "lagThreshold")
+			delete(event, "overBudget")
+		}
+	}
+}
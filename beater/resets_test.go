@@ -0,0 +1,42 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestOffsetReset(t *testing.T) {
+	bt := New()
+	bt.lagThreshold = 100
+	bt.offsetResetGrace = time.Minute
+	start := time.Now()
+	tick := func(offset int64, lag int64, after time.Duration) common.MapStr {
+		event := common.MapStr{"type": "consumer", "group": "billing", "topic": "orders", "partition": int32(0), "offset": offset, "lag": lag}
+		events := []common.MapStr{event}
+		bt.markLagThresholds(events)
+		bt.trackResets(events, start.Add(after))
+		return event
+	}
+
+	tick(5000, 10, 0)
+	reset := tick(1000, 4010, 10*time.Second)
+	if reset["offsetReset"] != true || reset["offsetResetDelta"] != int64(-4000) {
+		t.Errorf("expected a reset moving the offset back by 4000, got %v", reset)
+	}
+	if _, ok := reset["overThreshold"]; ok {
+		t.Errorf("expected the lag spike of a reset not to be flagged, got %v", reset)
+	}
+	during := tick(1500, 3510, 40*time.Second)
+	if _, ok := during["overThreshold"]; ok {
+		t.Errorf("expected no flag within the grace period, got %v", during)
+	}
+	if _, ok := during["offsetReset"]; ok {
+		t.Errorf("expected only the backward jump to be flagged a reset, got %v", during)
+	}
+	after := tick(2000, 3010, 2*time.Minute)
+	if after["overThreshold"] != true {
+		t.Errorf("expected the lag to be flagged again after the grace period, got %v", after)
+	}
+}
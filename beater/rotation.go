@@ -0,0 +1,42 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// groupsThisTick returns the groups the tick processes: all of them, or with max_groups_per_tick the next
+// max_groups_per_tick from the cursor round-robin, so that every group is covered across ticks
+func (bt *Kafkabeat) groupsThisTick() []string {
+	if bt.maxGroupsPerTick <= 0 || len(bt.groups) <= bt.maxGroupsPerTick {
+		return bt.groups
+	}
+	groups := make([]string, 0, bt.maxGroupsPerTick)
+	for i := 0; i < bt.maxGroupsPerTick; i++ {
+		groups = append(groups, bt.groups[(bt.groupCursor+i)%len(bt.groups)])
+	}
+	return groups
+}
+
+// advanceGroupCursor moves the cursor past the groups the tick processed, once the tick is done, so
+// that retried passes process the same groups
+func (bt *Kafkabeat) advanceGroupCursor() {
+	if bt.maxGroupsPerTick <= 0 || len(bt.groups) <= bt.maxGroupsPerTick {
+		bt.groupCursor = 0
+		return
+	}
+	bt.groupCursor = (bt.groupCursor + bt.maxGroupsPerTick) % len(bt.groups)
+}
+
+// groupsCoveredEvent reports the groups a tick processed when it processed only some of them
+func (bt *Kafkabeat) groupsCoveredEvent(groups []string) common.MapStr {
+	if len(groups) == len(bt.groups) {
+		return nil
+	}
+	return common.MapStr{
+		"@timestamp":    common.Time(time.Now()),
+		"type":          "kafkabeat",
+		"groupsCovered": groups,
+	}
+}
@@ -0,0 +1,35 @@
+package beater
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMaxGroupsPerTick(t *testing.T) {
+	bt := New()
+	bt.groups = []string{"audit", "billing", "fraud", "reporting", "search"}
+	bt.maxGroupsPerTick = 2
+	covered := make(map[string]bool)
+	expected := [][]string{{"audit", "billing"}, {"fraud", "reporting"}, {"search", "audit"}}
+	for tick, want := range expected {
+		groups := bt.groupsThisTick()
+		if !reflect.DeepEqual(groups, want) {
+			t.Errorf("expected tick %v to process %v, got %v", tick, want, groups)
+		}
+		if event := bt.groupsCoveredEvent(groups); event == nil || !reflect.DeepEqual(event["groupsCovered"], groups) {
+			t.Errorf("expected tick %v to report covering %v, got %v", tick, groups, event)
+		}
+		for _, group := range groups {
+			covered[group] = true
+		}
+		bt.advanceGroupCursor()
+	}
+	if len(covered) != len(bt.groups) {
+		t.Errorf("expected every group to be processed across the ticks, got %v", covered)
+	}
+
+	bt.maxGroupsPerTick = 0
+	if groups := bt.groupsThisTick(); !reflect.DeepEqual(groups, bt.groups) || bt.groupsCoveredEvent(groups) != nil {
+		t.Errorf("expected every group to be processed each tick without a cap, got %v", groups)
+	}
+}
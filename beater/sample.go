@@ -0,0 +1,96 @@
+package beater
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+// sampling picks the partitions of wide topics per partition events are published for
+type sampling struct {
+	every         int
+	count         int
+	minPartitions int
+}
+
+func newSampling(conf config.SampleConfig) (sampling, error) {
+	if conf.Every < 0 || conf.Count < 0 {
+		return sampling{}, fmt.Errorf("Partition samples need a positive every or count")
+	}
+	if conf.Every > 0 && conf.Count > 0 {
+		return sampling{}, fmt.Errorf("Partitions are sampled either every nth or as a count, not both")
+	}
+	return sampling{every: conf.Every, count: conf.Count, minPartitions: conf.MinPartitions}, nil
+}
+
+// applies is whether a topic with the number of partitions is sampled
+func (s sampling) applies(partitions int) bool {
+	return (s.every > 0 || s.count > 0) && partitions > s.minPartitions
+}
+
+// sample picks every nth partition or, for a count, those whose ids hash lowest, so the same
+// partitions are picked on every tick
+func (s sampling) sample(topic string, pids map[int32]int64) map[int32]bool {
+	sampled := make(map[int32]bool)
+	if s.every > 0 {
+		for pid := range pids {
+			if int(pid)%s.every == 0 {
+				sampled[pid] = true
+			}
+		}
+		return sampled
+	}
+	ranked := make([]int32, 0, len(pids))
+	for pid := range pids {
+		ranked = append(ranked, pid)
+	}
+	sort.Sort(byPartitionHash{topic, ranked})
+	for i := 0; i < s.count && i < len(ranked); i++ {
+		sampled[ranked[i]] = true
+	}
+	return sampled
+}
+
+func partitionHash(topic string, pid int32) uint32 {
+	hash := fnv.New32a()
+	fmt.Fprintf(hash, "%s/%d", topic, pid)
+	return hash.Sum32()
+}
+
+type byPartitionHash struct {
+	topic string
+	pids  []int32
+}
+
+func (b byPartitionHash) Len() int      { return len(b.pids) }
+func (b byPartitionHash) Swap(i, j int) { b.pids[i], b.pids[j] = b.pids[j], b.pids[i] }
+func (b byPartitionHash) Less(i, j int) bool {
+	return partitionHash(b.topic, b.pids[i]) < partitionHash(b.topic, b.pids[j])
+}
+
+// sampled keeps the events of sampled partitions
+func sampled(events []common.MapStr, sample map[int32]bool) []common.MapStr {
+	var kept []common.MapStr
+	for _, event := range events {
+		if sample[event["partition"].(int32)] {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
+
+// topicSummaryEvent sums up every partition of a sampled topic
+func topicSummaryEvent(topic string, pids map[int32]int64, sample map[int32]bool) common.MapStr {
+	return common.MapStr{
+		"@timestamp":        common.Time(time.Now()),
+		"type":              "topic_summary",
+		"topic":             topic,
+		"partitionCount":    len(pids),
+		"sampledPartitions": len(sample),
+		"totalSize":         totalSize(pids),
+	}
+}
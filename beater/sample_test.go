@@ -0,0 +1,63 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestSamplePartitions(t *testing.T) {
+	partitions := int32(20)
+	broker, metadata := newTestBroker(t, map[string]int32{"clicks": partitions})
+	defer broker.Close()
+	offsets := sarama.NewMockOffsetResponse(t)
+	for pid := int32(0); pid < partitions; pid++ {
+		offsets.SetOffset("clicks", pid, sarama.OffsetNewest, 10)
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest":   offsets,
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+
+	bt := New()
+	bt.topics = []string{"clicks"}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	bt.sampling = sampling{count: 5, minPartitions: 10}
+	var samples []map[int32]bool
+	for tick := 0; tick < 2; tick++ {
+		events := &capturePublisher{}
+		bt.collect(&beat.Beat{Events: events})
+		summaries := events.ofType("topic_summary")
+		if len(summaries) != 1 || summaries[0]["partitionCount"] != 20 || summaries[0]["totalSize"] != int64(200) {
+			t.Fatalf("expected a summary over all 20 partitions, got %v", summaries)
+		}
+		sample := make(map[int32]bool)
+		for _, event := range events.ofType("topic") {
+			sample[event["partition"].(int32)] = true
+		}
+		if len(sample) != 5 {
+			t.Fatalf("expected events for a sample of 5 partitions, got %v", sample)
+		}
+		samples = append(samples, sample)
+	}
+	for pid := range samples[0] {
+		if !samples[1][pid] {
+			t.Errorf("expected the same partitions to be sampled on every tick, got %v then %v", samples[0], samples[1])
+		}
+	}
+}
+
+func TestSampleEveryNth(t *testing.T) {
+	pids := map[int32]int64{}
+	for pid := int32(0); pid < 10; pid++ {
+		pids[pid] = 1
+	}
+	sample := sampling{every: 3}.sample("clicks", pids)
+	if len(sample) != 4 || !sample[0] || !sample[3] || !sample[6] || !sample[9] {
+		t.Errorf("expected every third partition to be sampled, got %v", sample)
+	}
+}
@@ -0,0 +1,124 @@
+package beater
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+const (
+	// how many times fetching a token is tried before giving up, and how long to wait between tries
+	tokenFetchAttempts = 3
+	tokenRetryDelay    = time.Second
+	// how long before a token expires it is refreshed
+	tokenRefreshMargin = 30 * time.Second
+	// how long a token is taken to last when the identity provider doesn't say
+	defaultTokenLifetime = 5 * time.Minute
+)
+
+// applySASLConfig authenticates the client with the configured SASL mechanism
+func applySASLConfig(conf config.SASLConfig, saramaConfig *sarama.Config) error {
+	switch conf.Mechanism {
+	case "":
+		return nil
+	case sarama.SASLTypeOAuth:
+		if conf.TokenURL == "" {
+			return fmt.Errorf("sasl.token_url is needed to fetch %v tokens", sarama.SASLTypeOAuth)
+		}
+		saramaConfig.Net.SASL.Enable = true
+		saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeOAuth
+		saramaConfig.Net.SASL.TokenProvider = newTokenProvider(conf)
+		return nil
+	default:
+		return fmt.Errorf("Unsupported sasl.mechanism %v, expected %v", conf.Mechanism, sarama.SASLTypeOAuth)
+	}
+}
+
+// tokenProvider fetches OAUTHBEARER tokens from an identity provider with the client credentials grant,
+// reusing a token until shortly before it expires
+type tokenProvider struct {
+	conf    config.SASLConfig
+	client  *http.Client
+	retry   time.Duration
+	lock    sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newTokenProvider(conf config.SASLConfig) *tokenProvider {
+	return &tokenProvider{conf: conf, client: &http.Client{Timeout: 10 * time.Second}, retry: tokenRetryDelay}
+}
+
+// Token returns the current token, fetching a new one once it nears expiry. Failed fetches are retried,
+// and while all fail a token that hasn't yet expired is still handed out rather than failing the connection
+func (p *tokenProvider) Token() (*sarama.AccessToken, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	now := time.Now()
+	if p.token != "" && now.Before(p.expires.Add(-tokenRefreshMargin)) {
+		return &sarama.AccessToken{Token: p.token}, nil
+	}
+	var err error
+	for attempt := 0; attempt < tokenFetchAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(p.retry)
+		}
+		var token string
+		var expiresIn time.Duration
+		token, expiresIn, err = p.fetch()
+		if err == nil {
+			p.token, p.expires = token, time.Now().Add(expiresIn)
+			return &sarama.AccessToken{Token: token}, nil
+		}
+		logp.Warn("Unable to fetch a token from %v: %v", p.conf.TokenURL, err)
+	}
+	if p.token != "" && now.Before(p.expires) {
+		return &sarama.AccessToken{Token: p.token}, nil
+	}
+	return nil, err
+}
+
+func (p *tokenProvider) fetch() (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(p.conf.Scopes) > 0 {
+		form.Set("scope", strings.Join(p.conf.Scopes, " "))
+	}
+	request, err := http.NewRequest("POST", p.conf.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.conf.ClientID != "" {
+		request.SetBasicAuth(p.conf.ClientID, p.conf.ClientSecret)
+	}
+	res, err := p.client.Do(request)
+	if err != nil {
+		return "", 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return "", 0, fmt.Errorf("token request failed with status %v", res.Status)
+	}
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", 0, err
+	}
+	if body.AccessToken == "" {
+		return "", 0, fmt.Errorf("token response carried no access_token")
+	}
+	if body.ExpiresIn <= 0 {
+		return body.AccessToken, defaultTokenLifetime, nil
+	}
+	return body.AccessToken, time.Duration(body.ExpiresIn) * time.Second, nil
+}
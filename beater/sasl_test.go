@@ -0,0 +1,84 @@
+package beater
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+func TestOAuthBearer(t *testing.T) {
+	requests := 0
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			// the first fetch fails and is retried
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if user, secret, _ := r.BasicAuth(); user != "kafkabeat" || secret != "s3cret" || r.FormValue("grant_type") != "client_credentials" {
+			t.Errorf("expected a client credentials grant for kafkabeat, got %v %v %v", user, secret, r.Form)
+		}
+		w.Write([]byte(`{"access_token": "token-1", "expires_in": 3600}`))
+	}))
+	defer identity.Close()
+
+	saramaConfig := sarama.NewConfig()
+	err := applySASLConfig(config.SASLConfig{Mechanism: "OAUTHBEARER", TokenURL: identity.URL, ClientID: "kafkabeat", ClientSecret: "s3cret"}, saramaConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saramaConfig.Net.SASL.Enable || saramaConfig.Net.SASL.Mechanism != sarama.SASLTypeOAuth {
+		t.Fatalf("expected OAUTHBEARER to be enabled, got %+v", saramaConfig.Net.SASL)
+	}
+	provider, ok := saramaConfig.Net.SASL.TokenProvider.(*tokenProvider)
+	if !ok {
+		t.Fatalf("expected the token provider to be installed, got %v", saramaConfig.Net.SASL.TokenProvider)
+	}
+	provider.retry = 0
+	for i := 0; i < 2; i++ {
+		token, err := provider.Token()
+		if err != nil || token.Token != "token-1" {
+			t.Fatalf("expected the fetched token, got %v, %v", token, err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("expected a retried fetch then the token reused, got %v requests", requests)
+	}
+	if err := applySASLConfig(config.SASLConfig{Mechanism: "GSSAPI"}, sarama.NewConfig()); err == nil {
+		t.Errorf("expected an unsupported mechanism to be rejected")
+	}
+}
+
+func TestOAuthBearerWithoutExpiry(t *testing.T) {
+	requests := 0
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"access_token": "token-1"}`))
+	}))
+	defer identity.Close()
+
+	provider := newTokenProvider(config.SASLConfig{Mechanism: "OAUTHBEARER", TokenURL: identity.URL})
+	provider.retry = 0
+	for i := 0; i < 2; i++ {
+		token, err := provider.Token()
+		if err != nil || token.Token != "token-1" {
+			t.Fatalf("expected the fetched token, got %v, %v", token, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected a token without expires_in to be reused, got %v requests", requests)
+	}
+	// once due for a refresh that fails, the token is still handed out until it expires
+	provider.expires = time.Now().Add(tokenRefreshMargin / 2)
+	if token, err := provider.Token(); err != nil || token.Token != "token-1" {
+		t.Errorf("expected the unexpired token to be handed out, got %v, %v", token, err)
+	}
+}
@@ -0,0 +1,45 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// scope counts what a collection pass covered, the distinct groups across every topic
+type scope struct {
+	topics         int
+	partitions     int
+	groups         map[string]bool
+	consumerEvents int
+}
+
+func newScope() *scope {
+	return &scope{groups: make(map[string]bool)}
+}
+
+// addTopic counts a topic collected with its sized partitions
+func (s *scope) addTopic(partitions int) {
+	s.topics++
+	s.partitions += partitions
+}
+
+// addConsumers counts the groups asked for their offsets on a topic and the consumer events built from them
+func (s *scope) addConsumers(groups []string, consumers []common.MapStr) {
+	for _, group := range groups {
+		s.groups[group] = true
+	}
+	s.consumerEvents += len(consumers)
+}
+
+// event builds the event describing the pass's scope, so that the monitored set silently shrinking shows
+func (s *scope) event() common.MapStr {
+	return common.MapStr{
+		"@timestamp":         common.Time(time.Now()),
+		"type":               "scope",
+		"topicCount":         s.topics,
+		"partitionCount":     s.partitions,
+		"groupCount":         len(s.groups),
+		"consumerEventCount": s.consumerEvents,
+	}
+}
@@ -0,0 +1,51 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestScopeEvent(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2, "clicks": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10).
+			SetOffset("orders", 1, sarama.OffsetNewest, 10).
+			SetOffset("clicks", 0, sarama.OffsetNewest, 10),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "shipping", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 4, "", sarama.ErrNoError).
+			SetOffset("billing", "orders", 1, 5, "", sarama.ErrNoError).
+			SetOffset("shipping", "clicks", 0, 6, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	bt := New()
+	bt.topics = []string{"orders", "clicks"}
+	bt.groups = []string{"billing", "shipping"}
+	bt.offsetBasis = highWatermark
+	published := &capturePublisher{}
+	bt.tick(&beat.Beat{Events: published})
+
+	scopes := published.ofType("scope")
+	if len(scopes) != 1 {
+		t.Fatalf("expected a scope event for the tick, got %v", scopes)
+	}
+	scope := scopes[0]
+	consumers := len(published.ofType("consumer"))
+	if scope["topicCount"] != 2 || scope["partitionCount"] != 3 || scope["groupCount"] != 2 || scope["consumerEventCount"] != consumers {
+		t.Errorf("expected 2 topics, 3 partitions, 2 groups and %v consumer events, got %v", consumers, scope)
+	}
+	if consumers != 3 {
+		t.Errorf("expected a consumer event per committed partition, got %v", published.ofType("consumer"))
+	}
+}
@@ -0,0 +1,135 @@
+package beater
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+var simulateSnapshot *string
+
+func init() {
+	simulateSnapshot = flag.String("simulate", "", "Run a collection pass against the cluster recorded in the JSON file, print the events and exit")
+}
+
+// snapshot is a recorded cluster a configuration is previewed against without touching a real cluster
+type snapshot struct {
+	// the size of each partition of each topic
+	Topics map[string]map[int32]int64 `json:"topics"`
+	// the offsets groups the brokers coordinate committed to each partition, by group and topic
+	Groups map[string]map[string]map[int32]int64 `json:"groups"`
+	// the offsets groups registered in Zookeeper committed to each partition, by group and topic
+	ZookeeperGroups map[string]map[string]map[int32]int64 `json:"zookeeper_groups"`
+}
+
+func loadSnapshot(path string) (*snapshot, error) {
+	recorded, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s snapshot
+	if err = json.Unmarshal(recorded, &s); err != nil {
+		return nil, fmt.Errorf("Invalid snapshot %v: %v", path, err)
+	}
+	return &s, nil
+}
+
+// serve starts a broker answering the requests a collection pass makes from the snapshot, as the single
+// broker leading every partition and coordinating every group
+func (s *snapshot) serve() (*sarama.MockBroker, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	reporter := snapshotReporter{}
+	broker := sarama.NewMockBrokerListener(reporter, 1, listener)
+	metadata := sarama.NewMockMetadataResponse(reporter).SetBroker(broker.Addr(), broker.BrokerID())
+	offsets := sarama.NewMockOffsetResponse(reporter)
+	for topic, sizes := range s.Topics {
+		for pid, size := range sizes {
+			metadata.SetLeader(topic, pid, broker.BrokerID())
+			offsets.SetOffset(topic, pid, sarama.OffsetNewest, size)
+			offsets.SetOffset(topic, pid, sarama.OffsetOldest, 0)
+		}
+	}
+	coordinators := sarama.NewMockFindCoordinatorResponse(reporter)
+	groups := sarama.NewMockListGroupsResponse(reporter)
+	descriptions := sarama.NewMockDescribeGroupsResponse(reporter)
+	committed := sarama.NewMockOffsetFetchResponse(reporter)
+	for group, topics := range s.Groups {
+		coordinators.SetCoordinator(sarama.CoordinatorGroup, group, broker)
+		groups.AddGroup(group, "consumer")
+		descriptions.AddGroupDescription(group, &sarama.GroupDescription{GroupId: group, State: "Stable"})
+		for topic, partitions := range topics {
+			for pid, offset := range partitions {
+				committed.SetOffset(group, topic, pid, offset, "", sarama.ErrNoError)
+			}
+		}
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":        metadata,
+		"OffsetRequest":          offsets,
+		"FindCoordinatorRequest": coordinators,
+		"ListGroupsRequest":      groups,
+		"DescribeGroupsRequest":  descriptions,
+		"OffsetFetchRequest":     committed,
+	})
+	return broker, nil
+}
+
+// stubZookeeper answers the reads from Zookeeper from the snapshot, the broker serving it being the one
+// registered
+func (s *snapshot) stubZookeeper(broker *sarama.MockBroker) {
+	brokerList = func() ([]string, error) {
+		return []string{broker.Addr()}, nil
+	}
+	zookeeperGroups = func() ([]string, error) {
+		groups := make([]string, 0, len(s.ZookeeperGroups))
+		for group := range s.ZookeeperGroups {
+			groups = append(groups, group)
+		}
+		sort.Strings(groups)
+		return groups, nil
+	}
+	zookeeperOffset = func(group string, topic string, pid int32) (int64, error) {
+		if offset, ok := s.ZookeeperGroups[group][topic][pid]; ok {
+			return offset, nil
+		}
+		return -1, nil
+	}
+}
+
+// simulate runs a single tick against the snapshot, the events being written to stdout
+func (bt *Kafkabeat) simulate(b *beat.Beat) {
+	bt.checkGroups(b)
+	bt.reportDuplicateGroups(b)
+	bt.tick(b)
+	bt.simulated.Close()
+}
+
+// snapshotReporter logs the requests the broker serving a snapshot cannot answer, such as the offsets of
+// partitions missing from it
+type snapshotReporter struct{}
+
+func (snapshotReporter) Error(args ...interface{}) {
+	logp.Err("Snapshot: %v", fmt.Sprint(args...))
+}
+
+func (snapshotReporter) Errorf(format string, args ...interface{}) {
+	logp.Err("Snapshot: %v", fmt.Sprintf(format, args...))
+}
+
+func (snapshotReporter) Fatal(args ...interface{}) {
+	logp.Critical("Snapshot: %v", fmt.Sprint(args...))
+}
+
+func (snapshotReporter) Fatalf(format string, args ...interface{}) {
+	logp.Critical("Snapshot: %v", fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,79 @@
+package beater
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+func TestSimulateSnapshot(t *testing.T) {
+	defer func(read func(interface{}, string) error, brokers func() ([]string, error), groups func() ([]string, error)) {
+		readConfig, brokerList, zookeeperGroups = read, brokers, groups
+	}(readConfig, brokerList, zookeeperGroups)
+	defer stubZookeeperOffsets(nil)()
+	// no Zookeeper to connect to, and a mirror and pushgateway that would fail Config if reached
+	readConfig = func(out interface{}, path string) error {
+		*out.(**config.Config) = &config.Config{Kafkabeat: config.KafkabeatConfig{
+			Mirror:      config.MirrorConfig{SourceBrokers: []string{"127.0.0.1:1"}},
+			PushGateway: config.PushGatewayConfig{URL: "http://127.0.0.1:1"},
+		}}
+		return nil
+	}
+	*simulateSnapshot = "testdata/snapshot.json"
+	defer func() { *simulateSnapshot = "" }()
+
+	bt := New()
+	published := &capturePublisher{}
+	b := &beat.Beat{Events: published}
+	lines := captureStdout(t, func() {
+		if err := bt.Config(b); err != nil {
+			t.Fatal(err)
+		}
+		// returns after the single pass rather than waiting on the ticker
+		if err := bt.Run(b); err != nil {
+			t.Fatal(err)
+		}
+	})
+	defer client.Close()
+	if bt.mirror != nil || bt.pushGateway != nil {
+		t.Errorf("expected a simulation to skip the mirror and pushgateway")
+	}
+	if len(published.events) != 0 {
+		t.Errorf("expected nothing published by a simulation, got %v", published.events)
+	}
+
+	sizes := map[int32]int64{}
+	lags := map[string]map[int32]int64{}
+	for _, line := range lines {
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("expected a JSON event on every line of stdout, got %q: %v", line, err)
+		}
+		switch event["type"] {
+		case "topic":
+			sizes[int32(event["partition"].(float64))] = int64(event["size"].(float64))
+		case "consumer":
+			group := event["group"].(string)
+			if lags[group] == nil {
+				lags[group] = map[int32]int64{}
+			}
+			lags[group][int32(event["partition"].(float64))] = int64(event["lag"].(float64))
+		}
+	}
+	if len(sizes) != 2 || sizes[0] != 100 || sizes[1] != 50 {
+		t.Errorf("expected the recorded sizes of orders, got %v", sizes)
+	}
+	expected := map[string]map[int32]int64{
+		"billing": {0: 10, 1: 0},
+		"legacy":  {0: 80},
+	}
+	for group, partitions := range expected {
+		for pid, lag := range partitions {
+			if found, ok := lags[group][pid]; !ok || found != lag {
+				t.Errorf("expected %v to lag %v on partition %v, got %v", group, lag, pid, lags[group])
+			}
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package beater
+
+import (
+	"expvar"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// rpcErrorCount is how many calls to the brokers failed since the beat started
+func rpcErrorCount() int64 {
+	return collectionStats.Get("rpcErrors").(*expvar.Int).Value()
+}
+
+// rediscover re-reads the brokers, topics and groups, checking and publishing what is reported of them
+func (bt *Kafkabeat) rediscover(b *beat.Beat) {
+	bt.refresh(b)
+	bt.checkGroups(b)
+	bt.reportDuplicateGroups(b)
+	bt.publishTopicAcls(b)
+}
+
+// refreshStable refreshes the topology of a stable_cluster, which is otherwise cached indefinitely
+// with neither the client's metadata nor discovery refreshed on a timer
+func (bt *Kafkabeat) refreshStable(b *beat.Beat, reason string) {
+	logp.Info("Refreshing the cluster topology on %s", reason)
+	if err := client.RefreshMetadata(); err != nil {
+		logp.Err("Unable to refresh the cluster metadata: %v", err)
+	}
+	bt.rediscover(b)
+}
@@ -0,0 +1,62 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestStableClusterRefresh(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest":   sarama.NewMockOffsetResponse(t).SetOffset("orders", 0, sarama.OffsetNewest, 10),
+		"ListGroupsRequest": sarama.NewMockListGroupsResponse(t).
+			AddGroup("billing", "consumer"),
+		// the beat is not authorized for the group, failing its coordinator lookup
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetError(sarama.CoordinatorGroup, "billing", sarama.ErrGroupAuthorizationFailed),
+	})
+	conf := sarama.NewConfig()
+	conf.Metadata.RefreshFrequency = 0
+	conf.Metadata.Retry.Max = 0
+	connectTestClient(t, broker, conf)
+	defer client.Close()
+	defer func(original func() ([]string, error)) { brokerList = original }(brokerList)
+	brokerList = func() ([]string, error) { return []string{broker.Addr()}, nil }
+	restore := zookeeperGroups
+	zookeeperGroups = func() ([]string, error) { return nil, nil }
+	defer func() { zookeeperGroups = restore }()
+	metadataRequests := func() int {
+		count := 0
+		for _, exchange := range broker.History() {
+			if _, ok := exchange.Request.(*sarama.MetadataRequest); ok {
+				count++
+			}
+		}
+		return count
+	}
+
+	bt := New()
+	bt.stableCluster = true
+	bt.brokers = []string{broker.Addr()}
+	bt.topics = []string{"orders"}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	b := &beat.Beat{Events: &capturePublisher{}}
+	connected := metadataRequests()
+	for tick := 0; tick < 10; tick++ {
+		bt.tick(b)
+	}
+	if metadataRequests() != connected {
+		t.Errorf("expected no metadata refresh across ticks of a stable cluster, got %v after %v", metadataRequests(), connected)
+	}
+
+	bt.groups = []string{"billing"}
+	bt.tick(b)
+	if metadataRequests() <= connected {
+		t.Errorf("expected a failing call to the brokers to refresh the metadata, got %v after %v", metadataRequests(), connected)
+	}
+}
@@ -0,0 +1,44 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// offsetSeen is a committed offset and when it was first seen at that value
+type offsetSeen struct {
+	offset int64
+	since  time.Time
+}
+
+// markStale tags the consumer events of groups whose committed offset has not moved for max_offset_age
+// while they have lag to consume. Neither the offset fetch API nor Zookeeper reads say when an offset
+// was committed, so its age is how long it has been seen unchanged across ticks
+func (bt *Kafkabeat) markStale(events []common.MapStr) {
+	if bt.maxOffsetAge <= 0 {
+		return
+	}
+	now := time.Now()
+	for _, event := range events {
+		key := stateKey("offsetSeen", seriesKey(event))
+		offset := event["offset"].(int64)
+		previous, ok := bt.state.Get(key)
+		if !ok || previous.(*offsetSeen).offset != offset {
+			bt.state.Put(key, &offsetSeen{offset, now})
+			continue
+		}
+		// keep the entry recent so that eviction does not reset the age of a stuck offset
+		bt.state.Put(key, previous)
+		lag, ok := lagOf(event)
+		if ok && lag > 0 && now.Sub(previous.(*offsetSeen).since) > bt.maxOffsetAge {
+			event["stale"] = true
+			// the lag is kept for the rollups and gauges, only the alerts on it being left out
+			if bt.suppressStaleLag {
+				delete(event, "overThreshold")
+				delete(event, "lagThreshold")
+				delete(event, "overBudget")
+			}
+		}
+	}
+}
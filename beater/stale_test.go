@@ -0,0 +1,44 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestStaleOffsets(t *testing.T) {
+	bt := New()
+	bt.maxOffsetAge = time.Hour
+	bt.suppressStaleLag = true
+	consumer := func(group string, lag int64) common.MapStr {
+		return common.MapStr{"type": "consumer", "group": group, "topic": "orders", "partition": int32(0), "offset": int64(100), "lag": lag,
+			"overThreshold": lag > 10, "lagThreshold": int64(10)}
+	}
+	// committed two hours ago and unchanged since
+	for _, group := range []string{"abandoned", "caught-up"} {
+		bt.state.Put(stateKey("offsetSeen", group, "orders", int32(0)), &offsetSeen{100, time.Now().Add(-2 * time.Hour)})
+	}
+
+	events := []common.MapStr{consumer("abandoned", 50), consumer("caught-up", 0), consumer("new", 50)}
+	bt.markStale(events)
+	if events[0]["stale"] != true {
+		t.Errorf("expected the long unchanged offset with lag to be stale, got %v", events[0])
+	}
+	if lag, ok := lagOf(events[0]); !ok || lag != 50 {
+		t.Errorf("expected the lag of a stale offset to be kept, got %v", events[0])
+	}
+	for _, field := range []string{"overThreshold", "lagThreshold"} {
+		if _, ok := events[0][field]; ok {
+			t.Errorf("expected the lag alerts of a stale offset to be suppressed, got %v", events[0])
+		}
+	}
+	if events[2]["overThreshold"] != true {
+		t.Errorf("expected the alerts of an offset that isn't stale to be kept, got %v", events[2])
+	}
+	for _, event := range events[1:] {
+		if _, ok := event["stale"]; ok {
+			t.Errorf("expected group %v not to be stale, got %v", event["group"], event)
+		}
+	}
+}
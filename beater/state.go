@@ -0,0 +1,103 @@
+package beater
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+const defaultStateCacheSize = 100000
+
+// stateCache holds the per partition state kept across ticks. Once more than size keys are held
+// the least recently updated are evicted so topic churn can't grow it without bound.
+type stateCache struct {
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+	lock    sync.Mutex
+}
+
+type stateEntry struct {
+	key   string
+	value interface{}
+}
+
+func newStateCache(size int) *stateCache {
+	if size <= 0 {
+		size = defaultStateCacheSize
+	}
+	return &stateCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// stateKey joins the identifying parts of a piece of state e.g. a feature, group, topic and partition
+func stateKey(parts ...interface{}) string {
+	fields := make([]string, len(parts))
+	for i, part := range parts {
+		fields[i] = fmt.Sprint(part)
+	}
+	return strings.Join(fields, "/")
+}
+
+// seriesKey keys the state kept for the group, topic and partition of an event, telling apart the offsets
+// of groups reported per store and the events of each broker
+func seriesKey(event common.MapStr) string {
+	parts := []interface{}{event["group"], event["topic"], event["partition"]}
+	if group, ok := event["group"].(string); ok && duplicateGroups[group] {
+		parts = append(parts, event["offsetStore"])
+	}
+	if brokerId, ok := event["brokerId"]; ok {
+		parts = append(parts, brokerId)
+	}
+	return stateKey(parts...)
+}
+
+// Get returns the state held for key. Reading does not count as an update.
+func (c *stateCache) Get(key string) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return element.Value.(*stateEntry).value, true
+}
+
+// Put stores the state for key, evicting the least recently updated keys beyond the cache size
+func (c *stateCache) Put(key string, value interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*stateEntry).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&stateEntry{key, value})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*stateEntry).key)
+	}
+}
+
+// Delete drops any state held for key
+func (c *stateCache) Delete(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+func (c *stateCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,25 @@
+package beater
+
+import "testing"
+
+func TestStateCacheEviction(t *testing.T) {
+	cache := newStateCache(2)
+	cache.Put(stateKey("lag", "billing", "orders", 0), int64(1))
+	cache.Put(stateKey("lag", "billing", "orders", 1), int64(2))
+	// updating partition 0 makes partition 1 the least recently updated
+	cache.Put(stateKey("lag", "billing", "orders", 0), int64(3))
+	cache.Put(stateKey("lag", "billing", "orders", 2), int64(4))
+
+	if cache.Len() != 2 {
+		t.Fatalf("expected the cache to hold 2 keys, got %v", cache.Len())
+	}
+	if _, ok := cache.Get(stateKey("lag", "billing", "orders", 1)); ok {
+		t.Errorf("expected partition 1 to be evicted")
+	}
+	if value, ok := cache.Get(stateKey("lag", "billing", "orders", 0)); !ok || value.(int64) != 3 {
+		t.Errorf("expected partition 0 to hold 3, got %v", value)
+	}
+	if _, ok := cache.Get(stateKey("lag", "billing", "orders", 2)); !ok {
+		t.Errorf("expected partition 2 to be held")
+	}
+}
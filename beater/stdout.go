@@ -0,0 +1,68 @@
+package beater
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/publisher"
+)
+
+// how stdout_output writes events to stdout
+const (
+	stdoutAlso = "also"
+	stdoutOnly = "only"
+)
+
+// stdoutLock keeps the lines of events written at once from interleaving
+var stdoutLock sync.Mutex
+
+// stdoutClient writes events to stdout as newline delimited JSON for log collecting sidecars to scrape,
+// passing them on to the client they were published through as well unless that is nil
+type stdoutClient struct {
+	also publisher.Client
+}
+
+func (c stdoutClient) PublishEvent(event common.MapStr, opts ...publisher.ClientOption) bool {
+	return c.PublishEvents([]common.MapStr{event}, opts...)
+}
+
+func (c stdoutClient) PublishEvents(events []common.MapStr, opts ...publisher.ClientOption) bool {
+	stdoutLock.Lock()
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			logp.Err("Unable to encode %v event for stdout: %v", event["type"], err)
+			continue
+		}
+		// a single write per line, so that nothing else written to stdout lands mid event
+		os.Stdout.Write(append(line, '\n'))
+	}
+	stdoutLock.Unlock()
+	if c.also != nil {
+		return c.also.PublishEvents(events, opts...)
+	}
+	return true
+}
+
+// checkStdoutOutput checks stdout_output is unset or one of its modes
+func checkStdoutOutput(mode string) error {
+	if mode != "" && mode != stdoutAlso && mode != stdoutOnly {
+		return fmt.Errorf("Unknown stdout_output %v, expected %v or %v", mode, stdoutAlso, stdoutOnly)
+	}
+	return nil
+}
+
+// toStdout wraps the client events are published through to write them to stdout as stdout_output says
+func (bt *Kafkabeat) toStdout(client publisher.Client) publisher.Client {
+	switch bt.stdoutOutput {
+	case stdoutAlso:
+		return stdoutClient{also: client}
+	case stdoutOnly:
+		return stdoutClient{}
+	}
+	return client
+}
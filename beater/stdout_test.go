@@ -0,0 +1,110 @@
+package beater
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+// captureStdout returns what run writes to stdout, line by line
+func captureStdout(t *testing.T, run func()) []string {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := os.Stdout
+	os.Stdout = writer
+	lines := make(chan []string)
+	go func() {
+		var read []string
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			read = append(read, scanner.Text())
+		}
+		lines <- read
+	}()
+	run()
+	os.Stdout = original
+	writer.Close()
+	return <-lines
+}
+
+func TestStdoutOutput(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest":   sarama.NewMockOffsetResponse(t).SetOffset("orders", 0, sarama.OffsetNewest, 10),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+
+	for _, mode := range []string{stdoutAlso, stdoutOnly} {
+		bt := New()
+		bt.topics = []string{"orders"}
+		bt.create_topic_docs = true
+		bt.offsetBasis = highWatermark
+		bt.stdoutOutput = mode
+		published := &capturePublisher{}
+		lines := captureStdout(t, func() { bt.tick(&beat.Beat{Events: published}) })
+		var topics int
+		for _, line := range lines {
+			var event map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				t.Fatalf("expected a JSON event on every line of stdout, got %q: %v", line, err)
+			}
+			if event["type"] == "topic" && event["topic"] == "orders" && event["size"] == float64(10) {
+				topics++
+			}
+		}
+		if topics != 1 {
+			t.Errorf("expected the topic event on stdout with %v, got %v", mode, lines)
+		}
+		if mode == stdoutAlso && len(published.ofType("topic")) != 1 {
+			t.Errorf("expected the topic event to be published as well, got %v", published.events)
+		}
+		if mode == stdoutOnly && len(published.events) != 0 {
+			t.Errorf("expected nothing published with only stdout, got %v", published.events)
+		}
+	}
+}
+
+func TestStdoutOutputFromConfig(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest":   sarama.NewMockOffsetResponse(t).SetOffset("orders", 0, sarama.OffsetNewest, 10),
+	})
+	defer stubConfig(config.KafkabeatConfig{
+		Zookeepers:   []string{"localhost:2181"},
+		Topics:       []string{"orders"},
+		Groups:       []string{},
+		StdoutOutput: stdoutOnly,
+	}, broker)()
+
+	bt := New()
+	b := &beat.Beat{Events: &capturePublisher{}}
+	lines := captureStdout(t, func() {
+		if err := bt.Config(b); err != nil {
+			t.Fatal(err)
+		}
+		bt.tick(b)
+	})
+	defer client.Close()
+	if len(lines) == 0 {
+		t.Fatalf("expected the events of the tick on stdout")
+	}
+	// nothing Config writes may come before or between the events
+	for _, line := range lines {
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Errorf("expected a JSON event on every line of stdout, got %q: %v", line, err)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package beater
+
+import (
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// summarizes is whether the topic has more partitions than max_partitions_per_topic, only a topic summary
+// then being published for it. Each topic is logged the first time it is summarized
+func (bt *Kafkabeat) summarizes(topic string, partitions int) bool {
+	if bt.maxPartitions <= 0 || partitions <= bt.maxPartitions {
+		return false
+	}
+	if !bt.summarizedTopics[topic] {
+		logp.Info("Topic %s has %v partitions, more than the %v of max_partitions_per_topic, publishing only its summary", topic, partitions, bt.maxPartitions)
+		bt.summarizedTopics[topic] = true
+	}
+	return true
+}
+
+// markSummaryLag adds the total lag of each group on the topic to its summary, from the group's rollup
+func markSummaryLag(summary common.MapStr, rollups []common.MapStr) {
+	lags := common.MapStr{}
+	for _, rollup := range rollups {
+		lags[rollup["group"].(string)] = rollup["totalLag"]
+	}
+	summary["lag"] = lags
+}
@@ -0,0 +1,65 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestMaxPartitionsPerTopic(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"clicks": 6, "orders": 2})
+	defer broker.Close()
+	offsets := sarama.NewMockOffsetResponse(t).
+		SetOffset("orders", 0, sarama.OffsetNewest, 10).
+		SetOffset("orders", 1, sarama.OffsetNewest, 10)
+	for pid := int32(0); pid < 6; pid++ {
+		offsets.SetOffset("clicks", pid, sarama.OffsetNewest, 10)
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest":   offsets,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "clicks", 0, 4, "", sarama.ErrNoError).
+			SetOffset("billing", "clicks", 1, 8, "", sarama.ErrNoError).
+			SetOffset("billing", "orders", 0, 7, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	bt := New()
+	bt.topics = []string{"clicks", "orders"}
+	bt.groups = []string{"billing"}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	bt.maxPartitions = 4
+	events := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: events})
+
+	for _, eventType := range []string{"topic", "consumer"} {
+		for _, event := range events.ofType(eventType) {
+			if event["topic"] != "orders" {
+				t.Errorf("expected no %v events for the summarized topic, got %v", eventType, event)
+			}
+		}
+	}
+	summaries := events.ofType("topic_summary")
+	if len(summaries) != 1 {
+		t.Fatalf("expected a summary of the topic above the limit only, got %v", summaries)
+	}
+	summary := summaries[0]
+	if summary["topic"] != "clicks" || summary["partitionCount"] != 6 || summary["totalSize"] != int64(60) {
+		t.Errorf("expected a summary over all 6 partitions of clicks, got %v", summary)
+	}
+	if lag := summary["lag"].(common.MapStr); lag["billing"] != int64(8) {
+		t.Errorf("expected the summary to total the group's lag of 8, got %v", summary["lag"])
+	}
+	if !bt.summarizedTopics["clicks"] || bt.summarizedTopics["orders"] {
+		t.Errorf("expected only clicks to be summarized, got %v", bt.summarizedTopics)
+	}
+}
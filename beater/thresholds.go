@@ -0,0 +1,33 @@
+package beater
+
+import "github.com/elastic/beats/libbeat/common"
+
+// lagThresholdFor returns the lag above which the group's consumption of the topic is flagged, looking
+// for one set for the group and topic, then for the topic, then in the topic's settings, then the global
+// one. 0 when none applies
+func (bt *Kafkabeat) lagThresholdFor(group string, topic string) int64 {
+	if threshold, ok := bt.lagThresholds[group+"/"+topic]; ok {
+		return threshold
+	}
+	if threshold, ok := bt.lagThresholds[topic]; ok {
+		return threshold
+	}
+	if threshold := bt.topicSettingFor(topic).lagThreshold; threshold != 0 {
+		return threshold
+	}
+	return bt.lagThreshold
+}
+
+// markLagThresholds flags the consumer events whose lag is above the threshold that applies to them
+func (bt *Kafkabeat) markLagThresholds(events []common.MapStr) {
+	for _, event := range events {
+		lag, ok := lagOf(event)
+		if !ok {
+			continue
+		}
+		threshold := bt.lagThresholdFor(event["group"].(string), event["topic"].(string))
+		if threshold > 0 && lag > threshold {
+			event.Update(common.MapStr{"overThreshold": true, "lagThreshold": threshold})
+		}
+	}
+}
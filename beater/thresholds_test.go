@@ -0,0 +1,34 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestLagThresholds(t *testing.T) {
+	bt := New()
+	bt.lagThreshold = 1000
+	bt.lagThresholds = map[string]int64{"orders": 500, "billing/orders": 100}
+	consumer := func(group string, topic string, lag int64) common.MapStr {
+		return common.MapStr{"type": "consumer", "group": group, "topic": topic, "partition": int32(0), "lag": lag}
+	}
+	cases := []struct {
+		event     common.MapStr
+		threshold int64
+	}{
+		{consumer("billing", "orders", 150), 100},
+		{consumer("billing", "orders", 50), 0},
+		{consumer("audit", "orders", 600), 500},
+		{consumer("audit", "orders", 400), 0},
+		{consumer("audit", "payments", 1200), 1000},
+		{consumer("audit", "payments", 900), 0},
+	}
+	for _, c := range cases {
+		bt.markLagThresholds([]common.MapStr{c.event})
+		_, flagged := c.event["overThreshold"]
+		if flagged != (c.threshold > 0) || (flagged && c.event["lagThreshold"] != c.threshold) {
+			t.Errorf("expected %v on %v with lag %v to be flagged against %v, got %v", c.event["group"], c.event["topic"], c.event["lag"], c.threshold, c.event)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package beater
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// brokerThrottles totals how long brokers throttled the responses of a tick for the client exceeding its quota
+var brokerThrottles = &throttles{}
+
+type throttles struct {
+	lock  sync.Mutex
+	total int64
+}
+
+func (t *throttles) record(throttleTimeMs int32) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.total += int64(throttleTimeMs)
+}
+
+// event reports the time throttled since the last call, nil when none was, so operators know to raise
+// the quota of the beat's client
+func (t *throttles) event() common.MapStr {
+	t.lock.Lock()
+	total := t.total
+	t.total = 0
+	t.lock.Unlock()
+	if total == 0 {
+		return nil
+	}
+	return common.MapStr{
+		"@timestamp":  common.Time(time.Now()),
+		"type":        "kafkabeat",
+		"throttledMs": total,
+	}
+}
@@ -0,0 +1,59 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestThrottledMs(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	throttled := &sarama.OffsetFetchResponse{Version: 3, ThrottleTimeMs: 250}
+	throttled.AddBlock("orders", 0, &sarama.OffsetFetchResponseBlock{Offset: 4, Err: sarama.ErrNoError})
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest":    sarama.NewMockWrapper(throttled),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+
+	bt := New()
+	bt.period = time.Hour
+	bt.topics = []string{"orders"}
+	bt.groups = []string{"billing"}
+	bt.offsetBasis = highWatermark
+	events := &capturePublisher{}
+	bt.tick(&beat.Beat{Events: events})
+
+	var reported []interface{}
+	for _, event := range events.ofType("kafkabeat") {
+		if throttledMs, ok := event["throttledMs"]; ok {
+			reported = append(reported, throttledMs)
+		}
+	}
+	if len(reported) != 1 || reported[0] != int64(250) {
+		t.Fatalf("expected the tick to report being throttled for 250ms, got %v", reported)
+	}
+	if consumers := events.ofType("consumer"); len(consumers) != 1 || consumers[0]["offset"] != int64(4) {
+		t.Errorf("expected the throttled response's offsets to be read, got %v", consumers)
+	}
+
+	// ticks that aren't throttled report nothing
+	throttled.ThrottleTimeMs = 0
+	events = &capturePublisher{}
+	bt.tick(&beat.Beat{Events: events})
+	for _, event := range events.ofType("kafkabeat") {
+		if _, ok := event["throttledMs"]; ok {
+			t.Errorf("expected no throttling reported, got %v", event)
+		}
+	}
+}
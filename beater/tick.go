@@ -0,0 +1,121 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+const (
+	defaultTickRetryDelay   = 500 * time.Millisecond
+	defaultFinalTickTimeout = 10 * time.Second
+	// how long past its timeout a final pass cut short is waited for, to publish what it collected
+	finalTickGrace = time.Second
+)
+
+// tick runs a collection pass and reports when it took longer than the period,
+// as the ticker then drops ticks and samples are silently lost
+func (bt *Kafkabeat) tick(b *beat.Beat) {
+	start := time.Now()
+	failures := rpcErrorCount()
+	bt.collectRetrying(b)
+	bt.advanceGroupCursor()
+	elapsed := time.Since(start)
+	if bt.stableCluster && rpcErrorCount() > failures {
+		bt.refreshStable(b, "calls to the brokers failing")
+	}
+	bt.publish(b, []common.MapStr{bt.scope.event()})
+	// published apart from the collection so that they don't count as a pass publishing something
+	bt.publish(b, collectionErrors.drain())
+	if event := brokerThrottles.event(); event != nil {
+		bt.publish(b, []common.MapStr{event})
+	}
+	if bt.pushGateway != nil {
+		bt.pushGateway.push()
+	}
+	if bt.autoTune {
+		bt.tune(elapsed)
+	}
+	if event := tickOverrunEvent(elapsed, bt.period); event != nil {
+		logp.Warn("Tick took %v, overrunning the period of %v. Consider raising the period", event["tickMs"], bt.period)
+		bt.publish(b, []common.MapStr{event})
+	}
+	if bt.reportBatchStats {
+		bt.publishBatchStats(b)
+	}
+	bt.ticks++
+	collectionStats.Add("ticks", 1)
+}
+
+// skipsTick is whether the tick that fired at the time is skipped, having fired while the previous tick
+// was still running. The ticker holds on to a tick fired meanwhile, so running it would start a pass
+// straight after the last one, back to back for as long as passes take longer than the period
+func (bt *Kafkabeat) skipsTick(fired time.Time) bool {
+	if !fired.Before(bt.tickDone) {
+		return false
+	}
+	collectionStats.Add("skippedTicks", 1)
+	logp.Warn("Skipping a tick, it fired while the previous tick ran for longer than the period of %v. Consider raising the period", bt.period)
+	return true
+}
+
+// collectRetrying runs a collection pass, running it again up to tick_retries times while a pass
+// publishes nothing because of errors, such as metadata being unavailable during a controller election
+func (bt *Kafkabeat) collectRetrying(b *beat.Beat) {
+	for attempt := 0; ; attempt++ {
+		published := bt.batch.events
+		err := bt.collect(b)
+		if err == nil || bt.batch.events > published {
+			return
+		}
+		if attempt >= bt.tickRetries {
+			if bt.tickRetries > 0 {
+				logp.Warn("Tick published nothing after %v retries, giving up: %v", bt.tickRetries, err)
+			}
+			return
+		}
+		logp.Warn("Tick published nothing, retrying in %v: %v", bt.tickRetryDelay, err)
+		select {
+		case <-bt.done:
+			return
+		case <-time.After(bt.tickRetryDelay):
+		}
+	}
+}
+
+// tickOverrunEvent builds the event reporting a tick overrunning the period, nil if it did not
+func tickOverrunEvent(elapsed time.Duration, period time.Duration) common.MapStr {
+	if elapsed <= period {
+		return nil
+	}
+	return common.MapStr{
+		"@timestamp":  common.Time(time.Now()),
+		"type":        "kafkabeat",
+		"tickOverrun": true,
+		"tickMs":      milliseconds(elapsed),
+		"periodMs":    milliseconds(period),
+		"overrunMs":   milliseconds(elapsed - period),
+	}
+}
+
+// finalTick runs a last collection pass once the beat is stopped, so that shutting down between ticks
+// still publishes a last data point. The pass is cut short after finalTickTimeout
+func (bt *Kafkabeat) finalTick(b *beat.Beat) {
+	if bt.finalTickTimeout <= 0 {
+		return
+	}
+	cutoff := make(chan struct{})
+	timeout := time.AfterFunc(bt.finalTickTimeout, func() { close(cutoff) })
+	defer timeout.Stop()
+	bt.cutoff = cutoff
+	if err := bt.collect(b); err != nil {
+		logp.Warn("Final collection pass failed: %v", err)
+	}
+	bt.publish(b, []common.MapStr{bt.scope.event()})
+	bt.publish(b, collectionErrors.flush())
+	if event := brokerThrottles.event(); event != nil {
+		bt.publish(b, []common.MapStr{event})
+	}
+}
@@ -0,0 +1,227 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/publisher"
+)
+
+func TestTickOverrun(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	broker.SetLatency(20 * time.Millisecond)
+
+	bt := New()
+	bt.period = 5 * time.Millisecond
+	bt.topics = []string{"orders"}
+	bt.offsetBasis = highWatermark
+	events := &capturePublisher{}
+	bt.tick(&beat.Beat{Events: events})
+
+	overruns := events.ofType("kafkabeat")
+	if len(overruns) != 1 || overruns[0]["tickOverrun"] != true {
+		t.Fatalf("expected a tick overrun event, got %v", overruns)
+	}
+	if overruns[0]["overrunMs"].(float64) < 15 {
+		t.Errorf("expected an overrun of at least 15ms, got %v", overruns[0])
+	}
+	if tickOverrunEvent(time.Millisecond, time.Second) != nil {
+		t.Errorf("expected no overrun event for a tick within the period")
+	}
+}
+
+func TestTickRetries(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	unavailable := sarama.NewMockMetadataResponse(t).SetBroker(broker.Addr(), broker.BrokerID())
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		// the topic is missing from the metadata when connecting and for the first collection pass
+		"MetadataRequest": sarama.NewMockSequence(unavailable, unavailable, unavailable, metadata),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10),
+	})
+	conf := sarama.NewConfig()
+	conf.Metadata.Retry.Max = 0
+	connectTestClient(t, broker, conf)
+	defer client.Close()
+
+	bt := New()
+	bt.period = time.Second
+	bt.topics = []string{"orders"}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	bt.tickRetries = 2
+	bt.tickRetryDelay = time.Millisecond
+	events := &capturePublisher{}
+	bt.tick(&beat.Beat{Events: events})
+
+	if topics := events.ofType("topic"); len(topics) != 1 {
+		t.Errorf("expected the retried pass to publish the topic, got %v", events.events)
+	}
+}
+
+func TestFinalTickOnStop(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+
+	bt := New()
+	bt.period = time.Hour
+	bt.refreshPeriod = time.Hour
+	bt.topics = []string{"orders"}
+	bt.groups = []string{}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	events := &capturePublisher{}
+	stopped := make(chan error)
+	go func() { stopped <- bt.Run(&beat.Beat{Events: events}) }()
+	bt.Stop()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Run to return once stopped")
+	}
+
+	// the period is never reached, so the topic events are those of the final pass
+	topics := events.ofType("topic")
+	if len(topics) != 1 || topics[0]["size"] != int64(10) {
+		t.Errorf("expected the final pass to publish the topic, got %v", topics)
+	}
+}
+
+// closedClientPublisher records the events published while the package client was already closed
+type closedClientPublisher struct {
+	capturePublisher
+	afterClose int
+}
+
+func (p *closedClientPublisher) PublishEvents(events []common.MapStr, opts ...publisher.ClientOption) bool {
+	if client.Closed() {
+		p.afterClose += len(events)
+	}
+	return p.capturePublisher.PublishEvents(events, opts...)
+}
+
+func TestStopWaitsForFinalTick(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10),
+	})
+	// the final pass is slow enough to still be running were Cleanup not held off
+	broker.SetLatency(50 * time.Millisecond)
+	connectTestClient(t, broker, nil)
+
+	bt := New()
+	bt.period = time.Hour
+	bt.refreshPeriod = time.Hour
+	bt.topics = []string{"orders"}
+	bt.groups = []string{}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	events := &closedClientPublisher{}
+	b := &beat.Beat{Events: events}
+	go bt.Run(b)
+	// as libbeat shuts a beat down
+	bt.Stop()
+	bt.Cleanup(b)
+
+	if topics := events.ofType("topic"); len(topics) != 1 || topics[0]["size"] != int64(10) {
+		t.Errorf("expected the final pass to publish the topic before Cleanup, got %v", events.events)
+	}
+	if events.afterClose > 0 {
+		t.Errorf("expected the final pass to publish before the client was closed, got %v events after", events.afterClose)
+	}
+}
+
+func TestTickSeq(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 2})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10).
+			SetOffset("orders", 1, sarama.OffsetNewest, 20),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+
+	bt := New()
+	bt.period = time.Hour
+	bt.topics = []string{"orders"}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	for tick := 0; tick < 2; tick++ {
+		events := &capturePublisher{}
+		bt.tick(&beat.Beat{Events: events})
+		topics := events.ofType("topic")
+		if len(topics) != 2 {
+			t.Fatalf("expected an event per partition, got %v", topics)
+		}
+		for _, event := range topics {
+			if event["tickSeq"] != tick {
+				t.Errorf("expected the events of tick %v to share its tickSeq, got %v", tick, event)
+			}
+		}
+	}
+}
+
+func TestSlowTicksSkipped(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	// each pass takes several periods
+	broker.SetLatency(50 * time.Millisecond)
+
+	bt := New()
+	bt.period = 10 * time.Millisecond
+	bt.refreshPeriod = time.Hour
+	bt.finalTickTimeout = 0
+	bt.topics = []string{"orders"}
+	bt.groups = []string{}
+	bt.create_topic_docs = true
+	bt.offsetBasis = highWatermark
+	skipped := collectionStat("skippedTicks")
+	events := &capturePublisher{}
+	stopped := make(chan error)
+	go func() { stopped <- bt.Run(&beat.Beat{Events: events}) }()
+	time.Sleep(400 * time.Millisecond)
+	bt.Stop()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Run to return once stopped")
+	}
+
+	// every pass overruns the period, so the tick the ticker held on to during each is skipped rather
+	// than starting the next pass straight away
+	passes := int64(len(events.ofType("topic")))
+	if passes == 0 || collectionStat("skippedTicks")-skipped < passes-1 {
+		t.Errorf("expected a tick skipped for each of the %v passes, got %v", passes, collectionStat("skippedTicks")-skipped)
+	}
+}
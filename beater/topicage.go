@@ -0,0 +1,92 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// how much of a partition is fetched from its oldest offset to read the timestamp of its oldest message.
+// From v3 of the fetch request a first batch larger than this is returned whole
+const oldestFetchBytes = 64 * 1024
+
+// oldestTimestamp reads the timestamp of the oldest message retained on the partition, false when it holds none
+var oldestTimestamp = func(topic string, pid int32) (time.Time, bool, error) {
+	oldest, err := client.GetOffset(topic, pid, sarama.OffsetOldest)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	newest, err := client.GetOffset(topic, pid, sarama.OffsetNewest)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if oldest >= newest {
+		return time.Time{}, false, nil
+	}
+	broker, err := client.Leader(topic, pid)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	start := time.Now()
+	defer brokerLatencies.since(broker.ID(), start)
+	// v1 messages carry timestamps, record batches from v4
+	request := &sarama.FetchRequest{Version: 2, MinBytes: 1}
+	if client.Config().Version.IsAtLeast(sarama.V0_11_0_0) {
+		request.Version = 4
+		request.MaxBytes = oldestFetchBytes
+	}
+	request.AddBlock(topic, pid, oldest, oldestFetchBytes, -1)
+	res, err := broker.Fetch(request)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	block := res.GetBlock(topic, pid)
+	if block == nil {
+		return time.Time{}, false, sarama.ErrIncompleteResponse
+	}
+	if block.Err != sarama.ErrNoError {
+		return time.Time{}, false, block.Err
+	}
+	for _, records := range block.RecordsSet {
+		if records.RecordBatch != nil && len(records.RecordBatch.Records) > 0 {
+			return records.RecordBatch.FirstTimestamp.Add(records.RecordBatch.Records[0].TimestampDelta), true, nil
+		}
+		if records.MsgSet != nil && len(records.MsgSet.Messages) > 0 {
+			// the messages a compressed message wraps carry their own timestamps
+			if inner := records.MsgSet.Messages[0].Messages(); len(inner) > 0 {
+				return inner[0].Msg.Timestamp, true, nil
+			}
+		}
+	}
+	// the first message is larger than fetched
+	return time.Time{}, false, nil
+}
+
+// topicAgeEvent builds the event reporting how far back the data retained on the topic reaches, as
+// oldestMessageAgeSeconds the age of the oldest message across its partitions. The field is left out when
+// none of the partitions holds a message
+func topicAgeEvent(topic string, pids map[int32]int64, now time.Time) common.MapStr {
+	event := common.MapStr{
+		"@timestamp": common.Time(now),
+		"type":       "topic_age",
+		"topic":      topic,
+	}
+	var oldest time.Time
+	for _, pid := range sortedPartitions(pids) {
+		timestamp, ok, err := oldestTimestamp(topic, pid)
+		if err != nil {
+			logp.Err("Unable to read the oldest message of partition %v and topic %s: %v", pid, topic, err)
+			collectionErrors.record("oldest_message", common.MapStr{"topic": topic, "partition": pid}, err)
+			continue
+		}
+		if ok && (oldest.IsZero() || timestamp.Before(oldest)) {
+			oldest = timestamp
+		}
+	}
+	if !oldest.IsZero() {
+		event["oldestMessageAgeSeconds"] = now.Sub(oldest).Seconds()
+	}
+	return event
+}
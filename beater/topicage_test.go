@@ -0,0 +1,53 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestTopicAge(t *testing.T) {
+	now := time.Now()
+	oldest := map[string]map[int32]time.Time{
+		"orders": {0: now.Add(-time.Hour), 1: now.Add(-3 * time.Hour), 2: now.Add(-2 * time.Hour)},
+	}
+	defer func(original func(string, int32) (time.Time, bool, error)) { oldestTimestamp = original }(oldestTimestamp)
+	oldestTimestamp = func(topic string, pid int32) (time.Time, bool, error) {
+		timestamp, ok := oldest[topic][pid]
+		return timestamp, ok, nil
+	}
+
+	event := topicAgeEvent("orders", map[int32]int64{0: 10, 1: 10, 2: 10, 3: 0}, now)
+	// the partition reaching back furthest, and the empty one not counting
+	if age := event["oldestMessageAgeSeconds"]; age != (3 * time.Hour).Seconds() {
+		t.Errorf("expected the age of orders to be that of its oldest partition, got %v", age)
+	}
+	if event := topicAgeEvent("clicks", map[int32]int64{0: 0}, now); event["oldestMessageAgeSeconds"] != nil {
+		t.Errorf("expected an empty topic to be published without an age, got %v", event)
+	}
+}
+
+func TestOldestTimestampFetched(t *testing.T) {
+	written := time.Unix(1700000000, 0)
+	fetch := &sarama.FetchResponse{Version: 4}
+	fetch.AddRecordWithTimestamp("orders", 0, nil, sarama.StringEncoder("first"), 5, written)
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetOldest, 5).
+			SetOffset("orders", 0, sarama.OffsetNewest, 9),
+		"FetchRequest": sarama.NewMockWrapper(fetch),
+	})
+	conf := sarama.NewConfig()
+	conf.Version = sarama.V0_11_0_0
+	connectTestClient(t, broker, conf)
+	defer client.Close()
+
+	timestamp, ok, err := oldestTimestamp("orders", 0)
+	if err != nil || !ok || !timestamp.Equal(written) {
+		t.Errorf("expected the timestamp of the message at the oldest offset, got %v %v %v", timestamp, ok, err)
+	}
+}
@@ -0,0 +1,46 @@
+package beater
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+// topicIndex is the index prefix the events of the topics whose names match its pattern are indexed under
+type topicIndex struct {
+	pattern string
+	index   string
+}
+
+// newTopicIndexes reads the topic_index_map entries, each of which needs a pattern and an index
+func newTopicIndexes(confs []config.TopicIndex) ([]topicIndex, error) {
+	var indexes []topicIndex
+	for _, conf := range confs {
+		if conf.Pattern == "" || conf.Index == "" {
+			return nil, fmt.Errorf("topic_index_map entries need a pattern and an index")
+		}
+		if _, err := path.Match(conf.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("Invalid topic_index_map pattern %v: %v", conf.Pattern, err)
+		}
+		indexes = append(indexes, topicIndex{conf.Pattern, conf.Index})
+	}
+	return indexes, nil
+}
+
+// addIndex overrides the index the event of a topic is indexed under with that of the first topic_index_map
+// entry matching the topic. libbeat merges beat.index into the beat field, the Elasticsearch output indexing
+// the event under it instead of the configured index
+func (bt *Kafkabeat) addIndex(event common.MapStr) {
+	topic, ok := event["topic"].(string)
+	if !ok {
+		return
+	}
+	for _, index := range bt.topicIndexes {
+		if matched, _ := path.Match(index.pattern, topic); matched {
+			event["beat"] = common.MapStr{"index": index.index}
+			return
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+func TestTopicIndexMap(t *testing.T) {
+	bt := New()
+	var err error
+	bt.topicIndexes, err = newTopicIndexes([]config.TopicIndex{
+		{Pattern: "payments-*", Index: "kafkabeat-payments"},
+		// shadowed by the entry before it
+		{Pattern: "payments-eu", Index: "kafkabeat-payments-eu"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := &capturePublisher{}
+	bt.publish(&beat.Beat{Events: published}, []common.MapStr{
+		{"type": "consumer", "topic": "payments-eu", "group": "billing", "partition": int32(0), "lag": int64(3)},
+		{"type": "topic", "topic": "orders", "partition": int32(0), "size": int64(10)},
+		{"type": "scope", "topics": 2},
+	})
+
+	if len(published.events) != 3 {
+		t.Fatalf("expected the events to be published, got %v", published.events)
+	}
+	for _, event := range published.events {
+		var index interface{}
+		if meta, ok := event["beat"].(common.MapStr); ok {
+			index = meta["index"]
+		}
+		if event["topic"] == "payments-eu" && index != "kafkabeat-payments" {
+			t.Errorf("expected the events of payments-eu to be indexed under kafkabeat-payments, got %v", event)
+		}
+		if event["topic"] != "payments-eu" && index != nil {
+			t.Errorf("expected unmatched events to use the configured index, got %v", event)
+		}
+	}
+	if _, err := newTopicIndexes([]config.TopicIndex{{Pattern: "orders-*"}}); err == nil {
+		t.Errorf("expected an entry without an index to be rejected")
+	}
+}
@@ -0,0 +1,139 @@
+package beater
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+// zookeeperTopicWatch lists the topics registered in Zookeeper, returning a channel firing once they next change
+var zookeeperTopicWatch = func() ([]string, <-chan zk.Event, error) {
+	list, changed, err := zClient.WatchTopics()
+	if err != nil {
+		return nil, nil, err
+	}
+	topics := make([]string, len(list))
+	for i, topic := range list {
+		topics[i] = topic.Name
+	}
+	return topics, changed, nil
+}
+
+// matchingTopics returns the sorted topics starting with the prefix
+func matchingTopics(topics []string, prefix string) []string {
+	matching := []string{}
+	for _, topic := range topics {
+		if strings.HasPrefix(topic, prefix) {
+			matching = append(matching, topic)
+		}
+	}
+	sort.Strings(matching)
+	return matching
+}
+
+// markDiscovered tags topic events with whether the topic was discovered rather than configured, to catch
+// topics discovery pulls in unexpectedly
+func markDiscovered(events []common.MapStr, discovered bool) {
+	for _, event := range events {
+		event["discovered"] = discovered
+	}
+}
+
+// refreshTopics re-reads the topics from the brokers' metadata, for discovered topics not watched in Zookeeper
+func (bt *Kafkabeat) refreshTopics() {
+	if err := client.RefreshMetadata(); err != nil {
+		logp.Warn("Unable to refresh the topic metadata, monitoring the topics previously found: %v", err)
+		return
+	}
+	topics, err := client.Topics()
+	if err != nil {
+		logp.Warn("Unable to list topics, monitoring the topics previously found: %v", err)
+		return
+	}
+	bt.setTopics(topics)
+}
+
+// setTopics monitors the discovered topics matching topic_prefix
+func (bt *Kafkabeat) setTopics(topics []string) {
+	bt.setTopicsAt(topics, time.Now())
+}
+
+// setTopicsAt monitors the discovered topics matching topic_prefix, holding back those that have been
+// present for less than new_topic_grace_period so that topics mid creation or short lived don't flood
+// the metrics. A topic that disappears starts its grace period over when it comes back
+func (bt *Kafkabeat) setTopicsAt(topics []string, now time.Time) {
+	topics = matchingTopics(topics, bt.topicPrefix)
+	if bt.newTopicGrace > 0 {
+		seen := make(map[string]time.Time, len(topics))
+		var stable []string
+		for _, topic := range topics {
+			since, ok := bt.topicsSeen[topic]
+			if !ok {
+				since = now
+			}
+			seen[topic] = since
+			if now.Sub(since) >= bt.newTopicGrace {
+				stable = append(stable, topic)
+			} else {
+				logp.Debug("kafkabeat", "Holding back topic %s, present since %v", topic, since)
+			}
+		}
+		bt.topicsSeen = seen
+		topics = stable
+		if topics == nil {
+			topics = []string{}
+		}
+	}
+	if !sameTopics(bt.topics, topics) {
+		logp.Info("Monitored topics changed from %v to %v", bt.topics, topics)
+	}
+	bt.topics = topics
+}
+
+func sameTopics(previous []string, current []string) bool {
+	if len(previous) != len(current) {
+		return false
+	}
+	for i := range current {
+		if previous[i] != current[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// watchTopics sends the topics registered in Zookeeper on the returned channel each time they change, so
+// that created and deleted topics are picked up without waiting for a refresh. Watches are one-shot so
+// each change sets a new one, and failures to set one are retried after the refresh period
+func (bt *Kafkabeat) watchTopics() <-chan []string {
+	changes := make(chan []string)
+	go func() {
+		for {
+			topics, changed, err := zookeeperTopicWatch()
+			if err != nil {
+				logp.Warn("Unable to watch the topics in Zookeeper, retrying in %v: %v", bt.refreshPeriod, err)
+				select {
+				case <-bt.done:
+					return
+				case <-time.After(bt.refreshPeriod):
+				}
+				continue
+			}
+			select {
+			case <-bt.done:
+				return
+			case changes <- topics:
+			}
+			select {
+			case <-bt.done:
+				return
+			case <-changed:
+			}
+		}
+	}()
+	return changes
+}
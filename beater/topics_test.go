@@ -0,0 +1,118 @@
+package beater
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/gingerwizard/kafkabeat/config"
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+func TestTopicWatch(t *testing.T) {
+	registered := [][]string{{"orders", "payments"}, {"orders", "orders-retry", "payments"}}
+	watches := make(chan chan zk.Event, len(registered))
+	restore := zookeeperTopicWatch
+	zookeeperTopicWatch = func() ([]string, <-chan zk.Event, error) {
+		changed := make(chan zk.Event, 1)
+		watches <- changed
+		topics := registered[0]
+		if len(registered) > 1 {
+			registered = registered[1:]
+		}
+		return topics, changed, nil
+	}
+	defer func() { zookeeperTopicWatch = restore }()
+
+	bt := New()
+	bt.refreshPeriod = time.Hour
+	bt.topicPrefix = "orders"
+	// stops the watch, Run never having been started for Stop to wait on
+	defer close(bt.done)
+	changes := bt.watchTopics()
+	receive := func() []string {
+		select {
+		case topics := <-changes:
+			return topics
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the watched topics to be sent")
+			return nil
+		}
+	}
+	bt.setTopics(receive())
+	if !reflect.DeepEqual(bt.topics, []string{"orders"}) {
+		t.Fatalf("expected the topics matching the prefix to be monitored, got %v", bt.topics)
+	}
+
+	// a topic created fires the watch, updating the monitored topics well before the hour's refresh
+	(<-watches) <- zk.Event{Type: zk.EventNodeChildrenChanged}
+	bt.setTopics(receive())
+	if !reflect.DeepEqual(bt.topics, []string{"orders", "orders-retry"}) {
+		t.Errorf("expected the created topic to be monitored, got %v", bt.topics)
+	}
+}
+
+func TestDiscoveredTopics(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1, "payments": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetNewest, 10).
+			SetOffset("payments", 0, sarama.OffsetNewest, 10),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+
+	for _, discovered := range []bool{false, true} {
+		bt := New()
+		bt.beatConfig = &config.Config{}
+		if !discovered {
+			bt.beatConfig.Kafkabeat.Topics = []string{"orders"}
+		}
+		bt.beatConfig.Kafkabeat.Groups = []string{}
+		if err := bt.resolve(); err != nil {
+			t.Fatal(err)
+		}
+		bt.offsetBasis = highWatermark
+		events := &capturePublisher{}
+		bt.collect(&beat.Beat{Events: events})
+		topics := events.ofType("topic")
+		if len(topics) == 0 {
+			t.Fatalf("expected topic events, got none")
+		}
+		for _, event := range topics {
+			if event["discovered"] != discovered {
+				t.Errorf("expected %v to be tagged discovered %v, got %v", event["topic"], discovered, event["discovered"])
+			}
+		}
+	}
+}
+
+func TestNewTopicGracePeriod(t *testing.T) {
+	bt := New()
+	bt.newTopicGrace = time.Minute
+	bt.topics = []string{"orders"}
+	bt.topicsSeen = map[string]time.Time{"orders": {}}
+	start := time.Now()
+	refreshes := []struct {
+		after     time.Duration
+		topics    []string
+		monitored []string
+	}{
+		{0, []string{"orders", "flapping"}, []string{"orders"}},
+		{30 * time.Second, []string{"orders"}, []string{"orders"}},
+		// back again, so the grace period starts over
+		{45 * time.Second, []string{"orders", "flapping"}, []string{"orders"}},
+		{90 * time.Second, []string{"orders", "flapping"}, []string{"orders"}},
+		{105 * time.Second, []string{"orders", "flapping"}, []string{"flapping", "orders"}},
+	}
+	for _, refresh := range refreshes {
+		bt.setTopicsAt(refresh.topics, start.Add(refresh.after))
+		if !reflect.DeepEqual(bt.topics, refresh.monitored) {
+			t.Errorf("expected %v to be monitored after %v, got %v", refresh.monitored, refresh.after, bt.topics)
+		}
+	}
+}
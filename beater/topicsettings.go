@@ -0,0 +1,166 @@
+package beater
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+// topicSetting overrides the settings of the topic it names, or of the topics whose names match its pattern
+type topicSetting struct {
+	name         string
+	pattern      string
+	offsetBasis  string
+	lagThreshold int64
+	// how often the topic is collected, a whole number of ticks. 0 for every tick
+	period time.Duration
+	// the partitions monitored, all of them when empty
+	partitions []int32
+	sampling   *sampling
+}
+
+func newTopicSetting(conf config.TopicSettings) (topicSetting, error) {
+	setting := topicSetting{
+		name:         conf.Name,
+		pattern:      conf.Pattern,
+		offsetBasis:  conf.OffsetBasis,
+		lagThreshold: conf.LagThreshold,
+		partitions:   conf.Partitions,
+	}
+	if _, err := path.Match(conf.Pattern, ""); err != nil {
+		return setting, fmt.Errorf("Invalid topic pattern %v: %v", conf.Pattern, err)
+	}
+	if conf.Period != "" {
+		var err error
+		if setting.period, err = time.ParseDuration(conf.Period); err != nil {
+			return setting, fmt.Errorf("Invalid topic period %v: %v", conf.Period, err)
+		}
+	}
+	if setting.offsetBasis != "" && setting.offsetBasis != highWatermark && setting.offsetBasis != lastStable {
+		return setting, fmt.Errorf("Unknown offset_basis %v, expected %v or %v", setting.offsetBasis, highWatermark, lastStable)
+	}
+	if conf.SamplePartitions != nil {
+		sampling, err := newSampling(*conf.SamplePartitions)
+		if err != nil {
+			return setting, err
+		}
+		setting.sampling = &sampling
+	}
+	return setting, nil
+}
+
+func (s topicSetting) matches(topic string) bool {
+	if s.name != "" {
+		return s.name == topic
+	}
+	matched, _ := path.Match(s.pattern, topic)
+	return matched
+}
+
+// useTopicSettings reads topic_defaults and the topic_settings entries, each of which needs a name or a pattern
+func (bt *Kafkabeat) useTopicSettings(defaults config.TopicSettings, settings []config.TopicSettings) error {
+	var err error
+	bt.topicDefaults, err = newTopicSetting(defaults)
+	if err != nil {
+		return err
+	}
+	bt.topicSettings = make([]topicSetting, 0, len(settings))
+	for _, conf := range settings {
+		if (conf.Name == "") == (conf.Pattern == "") {
+			return fmt.Errorf("topic_settings entries need either a name or a pattern")
+		}
+		setting, err := newTopicSetting(conf)
+		if err != nil {
+			return err
+		}
+		bt.topicSettings = append(bt.topicSettings, setting)
+	}
+	return nil
+}
+
+// topicSettingFor merges the settings of the first topic_settings entry matching the topic over topic_defaults.
+// Settings neither sets are left to the top level options
+func (bt *Kafkabeat) topicSettingFor(topic string) topicSetting {
+	merged := bt.topicDefaults
+	for _, setting := range bt.topicSettings {
+		if !setting.matches(topic) {
+			continue
+		}
+		if setting.offsetBasis != "" {
+			merged.offsetBasis = setting.offsetBasis
+		}
+		if setting.lagThreshold != 0 {
+			merged.lagThreshold = setting.lagThreshold
+		}
+		if setting.period != 0 {
+			merged.period = setting.period
+		}
+		if len(setting.partitions) > 0 {
+			merged.partitions = setting.partitions
+		}
+		if setting.sampling != nil {
+			merged.sampling = setting.sampling
+		}
+		break
+	}
+	return merged
+}
+
+// samplingFor returns how the topic's partitions are sampled
+func (bt *Kafkabeat) samplingFor(topic string) sampling {
+	if setting := bt.topicSettingFor(topic); setting.sampling != nil {
+		return *setting.sampling
+	}
+	return bt.sampling
+}
+
+// checkTopicSettings rejects topic periods shorter than the period, and topics whose offset_basis or
+// lag_threshold is set both by a topic_settings entry and by topic_offset_basis or lag_thresholds, as
+// which applies would be ambiguous
+func (bt *Kafkabeat) checkTopicSettings() error {
+	for _, setting := range append([]topicSetting{bt.topicDefaults}, bt.topicSettings...) {
+		if setting.period != 0 && setting.period < bt.period {
+			return fmt.Errorf("Topic period %v is shorter than the period %v", setting.period, bt.period)
+		}
+	}
+	for topic := range bt.topicOffsetBasis {
+		if setting, ok := bt.topicSettingEntry(topic); ok && setting.offsetBasis != "" {
+			return fmt.Errorf("The offset_basis of topic %v is set both in topic_offset_basis and topic_settings", topic)
+		}
+	}
+	for key := range bt.lagThresholds {
+		// keyed by topic, or by group/topic
+		topic := key
+		if i := strings.LastIndex(key, "/"); i >= 0 {
+			topic = key[i+1:]
+		}
+		if setting, ok := bt.topicSettingEntry(topic); ok && setting.lagThreshold != 0 {
+			return fmt.Errorf("The lag threshold of topic %v is set both in lag_thresholds and topic_settings", topic)
+		}
+	}
+	return nil
+}
+
+// topicSettingEntry returns the first topic_settings entry matching the topic
+func (bt *Kafkabeat) topicSettingEntry(topic string) (topicSetting, bool) {
+	for _, setting := range bt.topicSettings {
+		if setting.matches(topic) {
+			return setting, true
+		}
+	}
+	return topicSetting{}, false
+}
+
+// topicDue is whether the topic is collected this tick, a topic with its own period being collected on
+// every tick that is a multiple of the period's number of ticks, rounded up
+func (bt *Kafkabeat) topicDue(topic string) bool {
+	period := bt.topicSettingFor(topic).period
+	if period <= bt.period || bt.period <= 0 {
+		return true
+	}
+	every := int((period + bt.period - 1) / bt.period)
+	return bt.ticks%every == 0
+}
@@ -0,0 +1,123 @@
+package beater
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+func TestTopicSettings(t *testing.T) {
+	bt := New()
+	bt.offsetBasis = highWatermark
+	bt.lagThreshold = 1000
+	bt.sampling = sampling{count: 20, minPartitions: 100}
+	bt.topicOffsetBasis = map[string]string{"payments": highWatermark}
+	err := bt.useTopicSettings(config.TopicSettings{OffsetBasis: lastStable, LagThreshold: 100}, []config.TopicSettings{
+		{Name: "orders", LagThreshold: 10, Partitions: []int32{0, 1}},
+		{Pattern: "clicks-*", SamplePartitions: &config.SampleConfig{Every: 4}},
+		{Pattern: "*", LagThreshold: 50},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		topic      string
+		basis      string
+		threshold  int64
+		partitions []int32
+		sampling   sampling
+	}{
+		// the first matching entry wins, merged over the defaults
+		{"orders", lastStable, 10, []int32{0, 1}, bt.sampling},
+		{"clicks-eu", lastStable, 100, nil, sampling{every: 4}},
+		{"audit", lastStable, 50, nil, bt.sampling},
+		// topic_offset_basis takes precedence over topic_defaults for its topics
+		{"payments", highWatermark, 50, nil, bt.sampling},
+	}
+	for _, c := range cases {
+		if basis := bt.basisFor(c.topic); basis != c.basis {
+			t.Errorf("expected %v to be sized at %v, got %v", c.topic, c.basis, basis)
+		}
+		if threshold := bt.lagThresholdFor("billing", c.topic); threshold != c.threshold {
+			t.Errorf("expected a lag threshold of %v for %v, got %v", c.threshold, c.topic, threshold)
+		}
+		if partitions := bt.topicSettingFor(c.topic).partitions; !reflect.DeepEqual(partitions, c.partitions) {
+			t.Errorf("expected %v to have partitions %v pinned, got %v", c.topic, c.partitions, partitions)
+		}
+		if sampling := bt.samplingFor(c.topic); sampling != c.sampling {
+			t.Errorf("expected %v to be sampled %+v, got %+v", c.topic, c.sampling, sampling)
+		}
+	}
+
+	if err := bt.useTopicSettings(config.TopicSettings{}, []config.TopicSettings{{LagThreshold: 5}}); err == nil {
+		t.Errorf("expected an entry without a name or pattern to be rejected")
+	}
+	if err := bt.useTopicSettings(config.TopicSettings{}, []config.TopicSettings{{Name: "orders", OffsetBasis: "earliest"}}); err == nil {
+		t.Errorf("expected an unknown offset basis to be rejected")
+	}
+}
+
+func TestTopicSettingsConflicts(t *testing.T) {
+	bt := New()
+	bt.period = time.Second
+	if err := bt.useTopicSettings(config.TopicSettings{}, []config.TopicSettings{
+		{Name: "orders", OffsetBasis: lastStable},
+		{Pattern: "clicks-*", LagThreshold: 50},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	bt.topicOffsetBasis = map[string]string{"payments": highWatermark}
+	bt.lagThresholds = map[string]int64{"orders": 10}
+	if err := bt.checkTopicSettings(); err != nil {
+		t.Errorf("expected settings for distinct topics to be accepted, got %v", err)
+	}
+	bt.topicOffsetBasis = map[string]string{"orders": highWatermark}
+	if err := bt.checkTopicSettings(); err == nil {
+		t.Errorf("expected an offset basis set in both topic_offset_basis and topic_settings to be rejected")
+	}
+	bt.topicOffsetBasis = nil
+	bt.lagThresholds = map[string]int64{"billing/clicks-eu": 10}
+	if err := bt.checkTopicSettings(); err == nil {
+		t.Errorf("expected a lag threshold set in both lag_thresholds and topic_settings to be rejected")
+	}
+}
+
+func TestTopicPeriod(t *testing.T) {
+	bt := New()
+	bt.period = 10 * time.Second
+	if err := bt.useTopicSettings(config.TopicSettings{}, []config.TopicSettings{
+		{Name: "audit", Period: "30s"},
+		{Name: "clicks", Period: "25s"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bt.checkTopicSettings(); err != nil {
+		t.Fatal(err)
+	}
+	due := map[string][]int{}
+	for bt.ticks = 0; bt.ticks < 7; bt.ticks++ {
+		for _, topic := range []string{"audit", "clicks", "orders"} {
+			if bt.topicDue(topic) {
+				due[topic] = append(due[topic], bt.ticks)
+			}
+		}
+	}
+	// 25s rounds up to every third tick
+	expected := map[string][]int{"audit": {0, 3, 6}, "clicks": {0, 3, 6}, "orders": {0, 1, 2, 3, 4, 5, 6}}
+	if !reflect.DeepEqual(due, expected) {
+		t.Errorf("expected topics collected on ticks %v, got %v", expected, due)
+	}
+
+	if err := bt.useTopicSettings(config.TopicSettings{Period: "5s"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := bt.checkTopicSettings(); err == nil {
+		t.Errorf("expected a topic period shorter than the period to be rejected")
+	}
+	if err := bt.useTopicSettings(config.TopicSettings{}, []config.TopicSettings{{Name: "orders", Period: "often"}}); err == nil {
+		t.Errorf("expected an invalid topic period to be rejected")
+	}
+}
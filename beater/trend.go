@@ -0,0 +1,57 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// lagSample is a group's total lag on a topic and when it was seen
+type lagSample struct {
+	lag int64
+	at  time.Time
+}
+
+// markLagTrend adds how fast each group's lag on the topic is changing to its group_topic events
+func (bt *Kafkabeat) markLagTrend(rollups []common.MapStr) {
+	bt.trackLagTrend(rollups, time.Now())
+}
+
+// trackLagTrend adds the change in total lag per second since the last tick as lagSlope and whether the
+// lag is growing, shrinking or stable as lagTrend. While growing below the group's lag threshold the
+// seconds until the lag reaches it at the current rate are added as secondsToThreshold. Group totals over
+// only some of the partitions are neither compared nor kept, as they jump with the partitions fetched
+func (bt *Kafkabeat) trackLagTrend(rollups []common.MapStr, now time.Time) {
+	for _, rollup := range rollups {
+		lag, ok := rollup["totalLag"].(int64)
+		if !ok || rollup["partialData"] == true {
+			continue
+		}
+		key := stateKey("lagTrend", rollup["group"], rollup["topic"])
+		previous, seen := bt.state.Get(key)
+		bt.state.Put(key, &lagSample{lag, now})
+		if !seen {
+			continue
+		}
+		elapsed := now.Sub(previous.(*lagSample).at).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		slope := float64(lag-previous.(*lagSample).lag) / elapsed
+		trend := "stable"
+		if slope > 0 {
+			trend = "growing"
+		} else if slope < 0 {
+			trend = "shrinking"
+		}
+		rollup.Update(common.MapStr{"lagSlope": slope, "lagTrend": trend})
+		threshold := bt.lagThresholdFor(rollup["group"].(string), rollup["topic"].(string))
+		if slope > 0 && threshold > 0 {
+			remaining := float64(threshold - lag)
+			if remaining < 0 {
+				remaining = 0
+			}
+			rollup["secondsToThreshold"] = remaining / slope
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestLagTrend(t *testing.T) {
+	bt := New()
+	bt.lagThreshold = 10000
+	start := time.Now()
+	tick := func(lag int64, after time.Duration) common.MapStr {
+		rollup := common.MapStr{"type": "group_topic", "group": "billing", "topic": "orders", "totalLag": lag, "partialData": false}
+		bt.trackLagTrend([]common.MapStr{rollup}, start.Add(after))
+		return rollup
+	}
+
+	if first := tick(1000, 0); first["lagSlope"] != nil {
+		t.Errorf("expected no slope before a second tick, got %v", first)
+	}
+	tick(1500, 10*time.Second)
+	rising := tick(2000, 20*time.Second)
+	if rising["lagSlope"] != float64(50) || rising["lagTrend"] != "growing" {
+		t.Errorf("expected the lag to grow by 50 messages a second, got %v", rising)
+	}
+	// 8000 messages to go at 50 a second
+	if rising["secondsToThreshold"] != float64(160) {
+		t.Errorf("expected the threshold to be reached in 160s, got %v", rising)
+	}
+
+	shrinking := tick(1000, 30*time.Second)
+	if shrinking["lagSlope"] != float64(-100) || shrinking["lagTrend"] != "shrinking" {
+		t.Errorf("expected the lag to shrink by 100 messages a second, got %v", shrinking)
+	}
+	if _, ok := shrinking["secondsToThreshold"]; ok {
+		t.Errorf("expected no time to the threshold for shrinking lag, got %v", shrinking)
+	}
+	if stable := tick(1000, 40*time.Second); stable["lagTrend"] != "stable" {
+		t.Errorf("expected unchanged lag to be stable, got %v", stable)
+	}
+}
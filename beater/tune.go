@@ -0,0 +1,29 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+const defaultMaxConcurrency = 8
+
+// autoTuneThreshold is the share of the period a tick may take before auto_tune reacts
+const autoTuneThreshold = 0.8
+
+// tune doubles how many partitions are sized at once, up to max_concurrency, when a tick took up most
+// of the period. Once at the cap it can only recommend a longer period
+func (bt *Kafkabeat) tune(elapsed time.Duration) {
+	if float64(elapsed) <= autoTuneThreshold*float64(bt.period) {
+		return
+	}
+	if bt.concurrency >= bt.maxConcurrency {
+		logp.Warn("Tick took %v of the %v period at the maximum concurrency of %v, consider raising the period", elapsed, bt.period, bt.maxConcurrency)
+		return
+	}
+	bt.concurrency *= 2
+	if bt.concurrency > bt.maxConcurrency {
+		bt.concurrency = bt.maxConcurrency
+	}
+	logp.Info("Tick took %v of the %v period, sizing %v partitions at once", elapsed, bt.period, bt.concurrency)
+}
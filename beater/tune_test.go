@@ -0,0 +1,28 @@
+package beater
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoTuneConcurrency(t *testing.T) {
+	bt := New()
+	bt.period = time.Second
+	bt.maxConcurrency = 6
+
+	bt.tune(500 * time.Millisecond)
+	if bt.concurrency != 1 {
+		t.Errorf("expected a tick well within the period to leave the concurrency, got %v", bt.concurrency)
+	}
+	var ramp []int
+	for tick := 0; tick < 5; tick++ {
+		bt.tune(900 * time.Millisecond)
+		ramp = append(ramp, bt.concurrency)
+	}
+	expected := []int{2, 4, 6, 6, 6}
+	for i := range expected {
+		if ramp[i] != expected[i] {
+			t.Fatalf("expected the concurrency to ramp up as %v, got %v", expected, ramp)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package beater
+
+import (
+	"fmt"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// the units log sizes and lag are published in, by how many messages each counts
+var metricUnits = map[string]float64{
+	"messages":  1,
+	"thousands": 1e3,
+	"millions":  1e6,
+}
+
+const defaultMetricUnit = "messages"
+
+// scaledFields are the message counts published in the metric_unit as well, as <field>Scaled
+var scaledFields = []string{"size", "totalSize", "lag", "totalLag"}
+
+// metricUnitFor checks metric_unit names a known unit, returning the default when unset
+func metricUnitFor(unit string) (string, error) {
+	if unit == "" {
+		return defaultMetricUnit, nil
+	}
+	if _, ok := metricUnits[unit]; !ok {
+		return "", fmt.Errorf("Unknown metric_unit %v, expected messages, thousands or millions", unit)
+	}
+	return unit, nil
+}
+
+// addMetricUnit labels events carrying message counts with the unit, adding the counts scaled to it
+// alongside the raw ones rather than in their place so that no precision is lost on the raw values.
+// Unknown counts are left unscaled
+func (bt *Kafkabeat) addMetricUnit(event common.MapStr) {
+	counted := false
+	for _, field := range scaledFields {
+		value, ok := event[field].(int64)
+		if !ok {
+			continue
+		}
+		counted = true
+		if bt.metricUnit != defaultMetricUnit && value != unknownOffset {
+			event[field+"Scaled"] = float64(value) / metricUnits[bt.metricUnit]
+		}
+	}
+	if counted {
+		event["unit"] = bt.metricUnit
+	}
+}
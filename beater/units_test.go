@@ -0,0 +1,45 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestMetricUnit(t *testing.T) {
+	bt := New()
+	var err error
+	bt.metricUnit, err = metricUnitFor("thousands")
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := &capturePublisher{}
+	bt.publish(&beat.Beat{Events: published}, []common.MapStr{
+		{"type": "consumer", "group": "billing", "topic": "orders", "partition": int32(0), "lag": int64(12345), "logEndOffset": int64(20000)},
+		{"type": "topic", "topic": "orders", "partition": int32(1), "size": unknownOffset},
+		{"type": "kafkabeat", "tickMs": int64(20)},
+	})
+	consumer := published.events[0]
+	if consumer["lagScaled"] != 12.345 || consumer["lag"] != int64(12345) || consumer["unit"] != "thousands" {
+		t.Errorf("expected the lag of 12345 scaled to 12.345 thousands alongside the raw lag, got %v", consumer)
+	}
+	if _, ok := published.events[1]["sizeScaled"]; ok || published.events[1]["unit"] != "thousands" {
+		t.Errorf("expected an unknown size to be labelled but left unscaled, got %v", published.events[1])
+	}
+	if _, ok := published.events[2]["unit"]; ok {
+		t.Errorf("expected events without message counts to carry no unit, got %v", published.events[2])
+	}
+}
+
+func TestMetricUnitDefault(t *testing.T) {
+	bt := New()
+	published := &capturePublisher{}
+	bt.publish(&beat.Beat{Events: published}, []common.MapStr{{"type": "topic", "topic": "orders", "partition": int32(0), "size": int64(10)}})
+	if event := published.events[0]; event["unit"] != "messages" || event["sizeScaled"] != nil {
+		t.Errorf("expected sizes in messages with nothing scaled by default, got %v", event)
+	}
+	if _, err := metricUnitFor("bytes"); err == nil {
+		t.Errorf("expected an unknown unit to be rejected")
+	}
+}
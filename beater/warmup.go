@@ -0,0 +1,20 @@
+package beater
+
+import "github.com/elastic/beats/libbeat/common"
+
+// deltaFields are derived from what earlier ticks saw, so are misleading until enough ticks have run
+var deltaFields = []string{"commitsPerMinute", "stale", "partitionCountChanged", "previousPartitionCount",
+	"offsetReset", "offsetResetDelta", "caughtUpSeconds", "ownerChanged", "previousOwner",
+	"ownerChangesPerMinute", "lagSlope", "lagTrend", "secondsToThreshold", "lagEma", "abandoned"}
+
+// warmingUp is whether fewer than warmup_ticks ticks have completed
+func (bt *Kafkabeat) warmingUp() bool {
+	return bt.ticks < bt.warmupTicks
+}
+
+// dropDeltaFields strips the fields derived across ticks from the event, leaving the absolute ones
+func dropDeltaFields(event common.MapStr) {
+	for _, field := range deltaFields {
+		delete(event, field)
+	}
+}
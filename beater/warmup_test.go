@@ -0,0 +1,31 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestWarmupDropsDeltaFields(t *testing.T) {
+	bt := New()
+	bt.warmupTicks = 2
+	for tick := 0; tick < 4; tick++ {
+		published := &capturePublisher{}
+		b := &beat.Beat{Events: published}
+		bt.publish(b, []common.MapStr{{"type": "consumer", "group": "billing", "offset": int64(5), "lag": int64(3), "commitsPerMinute": 2.0, "stale": true, "lagEma": 2.5, "abandoned": true}})
+		bt.tick(b)
+
+		event := published.ofType("consumer")[0]
+		_, rate := event["commitsPerMinute"]
+		_, stale := event["stale"]
+		_, ema := event["lagEma"]
+		_, abandoned := event["abandoned"]
+		if warm := tick >= 2; rate != warm || stale != warm || ema != warm || abandoned != warm {
+			t.Errorf("expected delta fields published %v on tick %v, got %v", warm, tick, event)
+		}
+		if event["lag"] != int64(3) {
+			t.Errorf("expected absolute fields on tick %v, got %v", tick, event)
+		}
+	}
+}
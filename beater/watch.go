@@ -0,0 +1,136 @@
+package beater
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+// resolveWatch validates the watched topic, partition and group triples and indexes them by topic and group.
+// Watched groups must be known to Zookeeper or the brokers, so that a misspelt group fails at startup rather
+// than being watched for offsets it never commits
+func resolveWatch(triples []config.WatchConfig) (map[string]map[string][]int32, error) {
+	watch := make(map[string]map[string][]int32)
+	for _, triple := range triples {
+		pids, err := client.Partitions(triple.Topic)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to retrieve partitions for watched topic %v: %v", triple.Topic, err)
+		}
+		if !containsPartition(pids, int32(triple.Partition)) {
+			return nil, fmt.Errorf("Watched topic %v has no partition %v", triple.Topic, triple.Partition)
+		}
+		if triple.Group == "" {
+			return nil, fmt.Errorf("No group given to watch partition %v of topic %v", triple.Partition, triple.Topic)
+		}
+		if watch[triple.Topic] == nil {
+			watch[triple.Topic] = make(map[string][]int32)
+		}
+		watch[triple.Topic][triple.Group] = append(watch[triple.Topic][triple.Group], int32(triple.Partition))
+	}
+	known, _, err := getGroups(false)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to check the watched groups exist: %v", err)
+	}
+	_, groups := watchedTopicsAndGroups(watch)
+	if unknown := unknownGroups(groups, known); len(unknown) > 0 {
+		return nil, fmt.Errorf("Watched groups %v are not known to Zookeeper or the brokers", unknown)
+	}
+	return watch, nil
+}
+
+// collectWatched runs a collection pass over just the watched partitions and groups
+func (bt *Kafkabeat) collectWatched(b *beat.Beat) {
+	topics, _ := watchedTopicsAndGroups(bt.watch)
+	leaders := leadershipCounts(topics)
+	var usage *diskUsage
+	if bt.partitionBytes {
+		usage = getDiskUsage()
+		bt.publish(b, usage.events())
+	}
+	for _, topic := range topics {
+		groups := bt.watch[topic]
+		var pids []int32
+		for _, partitions := range groups {
+			for _, pid := range partitions {
+				if !containsPartition(pids, pid) {
+					pids = append(pids, pid)
+				}
+			}
+		}
+		sortPartitions(pids)
+		sizes := getPartitionSizes(topic, pids, bt.basisFor(topic), bt.concurrency, bt.cutoff)
+		bt.scope.addTopic(len(sizes))
+		partitionEvents := topicEvents(topic, sizes)
+		markDiscovered(partitionEvents, false)
+		bt.markPartitionCountChange(topic, partitionEvents)
+		markHotBrokers(partitionEvents, leaders)
+		usage.mark(partitionEvents)
+		bt.publish(b, partitionEvents)
+		names := make([]string, 0, len(groups))
+		for group := range groups {
+			names = append(names, group)
+		}
+		sort.Strings(names)
+		for _, group := range names {
+			partitions := groups[group]
+			if bt.stopped() {
+				return
+			}
+			watched := make(map[int32]int64)
+			for _, pid := range partitions {
+				if size, ok := sizes[pid]; ok {
+					watched[pid] = size
+				}
+			}
+			events := processGroups([]string{group}, topic, watched)
+			bt.scope.addConsumers([]string{group}, events)
+			if len(events) < len(watched) {
+				logp.Warn("Group %s has no committed offsets for some watched partitions %v of topic %s", group, partitions, topic)
+			}
+			bt.markCommitRate(events)
+			bt.markOwnerChanges(events)
+			bt.markLagThresholds(events)
+			bt.markLagBudgets(events)
+			// after the alerts, which stale offsets may leave out
+			bt.markStale(events)
+			bt.markOffsetResets(events)
+			bt.markAbandoned(events)
+			bt.markLagEma(events)
+			bt.capLag(events)
+			rollups := groupTopicEvents(topic, watched, events)
+			markLeaderLag(leadersOf(partitionEvents), rollups, events)
+			bt.publish(b, bt.compactConsumers(events, rollups))
+			bt.markLagBudgets(rollups)
+			bt.markLagTrend(rollups)
+			bt.markCaughtUp(rollups)
+			bt.capLag(rollups)
+			bt.publish(b, rollups)
+		}
+	}
+}
+
+// watchedTopicsAndGroups lists the distinct topics and groups being watched
+func watchedTopicsAndGroups(watch map[string]map[string][]int32) ([]string, []string) {
+	topics := []string{}
+	var groups []string
+	for topic, watchedGroups := range watch {
+		topics = append(topics, topic)
+		for group := range watchedGroups {
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(topics)
+	return topics, mergeGroups(groups)
+}
+
+func containsPartition(pids []int32, pid int32) bool {
+	for _, candidate := range pids {
+		if candidate == pid {
+			return true
+		}
+	}
+	return false
+}
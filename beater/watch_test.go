@@ -0,0 +1,139 @@
+package beater
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/gingerwizard/kafkabeat/config"
+)
+
+func TestWatchOnlyEmitsWatchedTriples(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 3, "payments": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 1, sarama.OffsetNewest, 10),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "orders", 0, 4, "", sarama.ErrNoError).
+			SetOffset("billing", "orders", 1, 5, "", sarama.ErrNoError).
+			SetOffset("billing", "payments", 0, 6, "", sarama.ErrNoError),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+		"ListGroupsRequest":     sarama.NewMockListGroupsResponse(t).AddGroup("billing", "consumer"),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+	restore := zookeeperGroups
+	zookeeperGroups = func() ([]string, error) { return nil, nil }
+	defer func() { zookeeperGroups = restore }()
+
+	bt := New()
+	bt.offsetBasis = highWatermark
+	bt.beatConfig = &config.Config{Kafkabeat: config.KafkabeatConfig{
+		Watch: []config.WatchConfig{{Topic: "orders", Partition: 1, Group: "billing"}},
+	}}
+	if err := bt.resolve(); err != nil {
+		t.Fatal(err)
+	}
+	events := &capturePublisher{}
+	bt.collect(&beat.Beat{Events: events})
+
+	for _, eventType := range []string{"topic", "consumer"} {
+		typed := events.ofType(eventType)
+		if len(typed) != 1 {
+			t.Fatalf("expected a single %v event, got %v", eventType, typed)
+		}
+		if typed[0]["topic"] != "orders" || typed[0]["partition"] != int32(1) {
+			t.Errorf("expected the %v event to be for orders partition 1, got %v", eventType, typed[0])
+		}
+	}
+}
+
+func TestWatchRejectsUnknownPartition(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{"MetadataRequest": metadata})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+
+	if _, err := resolveWatch([]config.WatchConfig{{Topic: "orders", Partition: 3, Group: "billing"}}); err == nil {
+		t.Errorf("expected watching a missing partition to fail")
+	}
+}
+
+func TestWatchRejectsUnknownGroup(t *testing.T) {
+	broker, metadata := newTestBroker(t, map[string]int32{"orders": 1})
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":   metadata,
+		"ListGroupsRequest": sarama.NewMockListGroupsResponse(t).AddGroup("billing", "consumer"),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	restore := zookeeperGroups
+	zookeeperGroups = func() ([]string, error) { return []string{"legacy"}, nil }
+	defer func() { zookeeperGroups = restore }()
+
+	if _, err := resolveWatch([]config.WatchConfig{{Topic: "orders", Partition: 0, Group: "biling"}}); err == nil {
+		t.Errorf("expected watching a group missing from Zookeeper and the brokers to fail")
+	}
+	for _, group := range []string{"billing", "legacy"} {
+		if _, err := resolveWatch([]config.WatchConfig{{Topic: "orders", Partition: 0, Group: group}}); err != nil {
+			t.Errorf("expected %v to be watched, got %v", group, err)
+		}
+	}
+}
+
+func TestWatchOrdersEventsByTopic(t *testing.T) {
+	topics := []string{"audit", "orders", "payments", "refunds", "shipping"}
+	partitions := make(map[string]int32)
+	offsets := sarama.NewMockOffsetResponse(t)
+	committed := sarama.NewMockOffsetFetchResponse(t)
+	var watch []config.WatchConfig
+	for _, topic := range topics {
+		partitions[topic] = 1
+		offsets.SetOffset(topic, 0, sarama.OffsetNewest, 10)
+		committed.SetOffset("billing", topic, 0, 4, "", sarama.ErrNoError)
+		watch = append(watch, config.WatchConfig{Topic: topic, Partition: 0, Group: "billing"})
+	}
+	broker, metadata := newTestBroker(t, partitions)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest":   offsets,
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", broker),
+		"OffsetFetchRequest":    committed,
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+		"ListGroupsRequest":     sarama.NewMockListGroupsResponse(t).AddGroup("billing", "consumer"),
+	})
+	connectTestClient(t, broker, nil)
+	defer client.Close()
+	defer stubZookeeperOffsets(nil)()
+	restore := zookeeperGroups
+	zookeeperGroups = func() ([]string, error) { return nil, nil }
+	defer func() { zookeeperGroups = restore }()
+
+	bt := New()
+	bt.offsetBasis = highWatermark
+	bt.beatConfig = &config.Config{Kafkabeat: config.KafkabeatConfig{Watch: watch}}
+	if err := bt.resolve(); err != nil {
+		t.Fatal(err)
+	}
+	for tick := 0; tick < 5; tick++ {
+		events := &capturePublisher{}
+		bt.collect(&beat.Beat{Events: events})
+		var order []string
+		for _, event := range events.ofType("consumer") {
+			order = append(order, event["topic"].(string))
+		}
+		if !reflect.DeepEqual(order, topics) {
+			t.Fatalf("expected the consumer events of tick %v in topic order %v, got %v", tick, topics, order)
+		}
+	}
+}
@@ -7,10 +7,147 @@ type Config struct {
 	Kafkabeat KafkabeatConfig
 }
 
+// KafkabeatConfig holds the kafkabeat options. They are unpacked by libbeat, which matches the config: tags of
+// the fields rather than yaml: ones
 type KafkabeatConfig struct {
-	Period string `yaml:"period"`
-	Groups [] string `yaml:"groups"`
-	Topics [] string `yaml:"topics"`
-	Zookeepers [] string `yaml:"zookeepers"`
-	Chroot string `yaml:"chroot"`
+	Period            string            `config:"period"`
+	PeriodJitter      string            `config:"period_jitter"`
+	Groups            []string          `config:"groups"`
+	GroupNamePattern  string            `config:"group_name_pattern"`
+	Topics            []string          `config:"topics"`
+	TopicPrefix       string            `config:"topic_prefix"`
+	TopicWatch        bool              `config:"topic_watch"`
+	NewTopicGrace     string            `config:"new_topic_grace_period"`
+	Zookeepers        []string          `config:"zookeepers"`
+	Chroot            string            `config:"chroot"`
+	KafkaVersion      string            `config:"kafka_version"`
+	RefreshPeriod     string            `config:"refresh_period"`
+	BrokerSource      string            `config:"broker_source"`
+	StableCluster     bool              `config:"stable_cluster"`
+	StateCacheSize    int               `config:"state_cache_size"`
+	SuppressUnchanged bool              `config:"suppress_unchanged"`
+	MaxSuppressTicks  int               `config:"max_suppress_ticks"`
+	OffsetBasis       string            `config:"offset_basis"`
+	TopicOffsetBasis  map[string]string `config:"topic_offset_basis"`
+	Watch             []WatchConfig     `config:"watch"`
+	UseAdminClient    bool              `config:"use_admin_client"`
+	PublishBatchStats bool              `config:"publish_batch_stats"`
+	MaxOffsetAge      string            `config:"max_offset_age"`
+	SuppressStaleLag  bool              `config:"suppress_stale_lag"`
+	MetricsetName     string            `config:"metricset_name"`
+	MetricsetModule   string            `config:"metricset_module"`
+	MetricUnit        string            `config:"metric_unit"`
+	WarmupTicks       int               `config:"warmup_ticks"`
+	Grouping          string            `config:"grouping"`
+	TimestampAlign    string            `config:"timestamp_alignment"`
+	TickRetries       int               `config:"tick_retries"`
+	PartitionBytes    bool              `config:"partition_bytes"`
+	TopicAge          bool              `config:"topic_age"`
+	OffsetSpecs       []OffsetSpec      `config:"offset_specs"`
+	Concurrency       int               `config:"concurrency"`
+	AutoTune          bool              `config:"auto_tune"`
+	MaxConcurrency    int               `config:"max_concurrency"`
+	OffsetStores      []string          `config:"offset_stores"`
+	OffsetsTopic      bool              `config:"consume_offsets_topic"`
+	Pipelines         map[string]string `config:"pipelines"`
+	TopicIndexMap     []TopicIndex      `config:"topic_index_map"`
+	StdoutOutput      string            `config:"stdout_output"`
+	LagThreshold      int64             `config:"lag_threshold"`
+	LagThresholds     map[string]int64  `config:"lag_thresholds"`
+	LagBudget         int64             `config:"lag_budget"`
+	LagBudgets        map[string]int64  `config:"lag_budgets"`
+	LagCap            int64             `config:"lag_cap"`
+	LagEmaAlpha       float64           `config:"lag_ema_alpha"`
+	CompactLagBelow   int64             `config:"compact_lag_below"`
+	OffsetResetGrace  string            `config:"offset_reset_grace"`
+	AbandonedAfter    string            `config:"abandoned_after"`
+	SuppressAbandoned bool              `config:"suppress_abandoned_alerts"`
+	MaxPartitions     int               `config:"max_partitions_per_topic"`
+	MinTopicSize      int64             `config:"min_topic_size"`
+	SamplePartitions  SampleConfig      `config:"sample_partitions"`
+	TopicDefaults     TopicSettings     `config:"topic_defaults"`
+	TopicSettings     []TopicSettings   `config:"topic_settings"`
+	ActiveGroupsOnly  bool              `config:"active_groups_only"`
+	NormalizeGroups   bool              `config:"normalize_group_names"`
+	GroupStates       []string          `config:"consumer_group_states"`
+	AggregateGroups   []AggregateConfig `config:"aggregate_groups"`
+	SuppressAggregate bool              `config:"suppress_aggregated_groups"`
+	ConsumedTopics    bool              `config:"consumed_topics_only"`
+	TopicAcls         bool              `config:"topic_acls"`
+	MaxGroupsPerTick  int               `config:"max_groups_per_tick"`
+	FinalTickTimeout  string            `config:"final_tick_timeout"`
+	PauseThreshold    string            `config:"pause_threshold"`
+	EmitErrors        bool              `config:"emit_errors"`
+	SkipLeaderless    *bool             `config:"skip_leaderless"`
+	ErrorDedupeWindow string            `config:"error_dedupe_window"`
+	CollectorName     string            `config:"collector_name"`
+	ClusterIdField    string            `config:"cluster_id_field"`
+	ExpvarBind        string            `config:"expvar_bind"`
+	PushGateway       PushGatewayConfig `config:"pushgateway"`
+	Metadata          MetadataConfig    `config:"metadata"`
+	SASL              SASLConfig        `config:"sasl"`
+	Mirror            MirrorConfig      `config:"mirror"`
+}
+
+type MirrorConfig struct {
+	SourceBrokers []string          `config:"source_brokers"`
+	Topics        map[string]string `config:"topics"`
+	SASL          *SASLConfig       `config:"sasl"`
+}
+
+type AggregateConfig struct {
+	Name    string `config:"name"`
+	Pattern string `config:"pattern"`
+}
+
+type OffsetSpec struct {
+	Name string `config:"name"`
+	Spec string `config:"spec"`
+}
+
+type TopicIndex struct {
+	Pattern string `config:"pattern"`
+	Index   string `config:"index"`
+}
+
+type WatchConfig struct {
+	Topic     string `config:"topic"`
+	Partition int    `config:"partition"`
+	Group     string `config:"group"`
+}
+
+type SampleConfig struct {
+	Every         int `config:"every"`
+	Count         int `config:"count"`
+	MinPartitions int `config:"min_partitions"`
+}
+
+type PushGatewayConfig struct {
+	URL      string `config:"url"`
+	Job      string `config:"job"`
+	Instance string `config:"instance"`
+}
+
+type MetadataConfig struct {
+	RefreshFrequency string `config:"refresh_frequency"`
+	Retries          *int   `config:"retries"`
+	RetryBackoff     string `config:"retry_backoff"`
+}
+
+type TopicSettings struct {
+	Name             string        `config:"name"`
+	Pattern          string        `config:"pattern"`
+	Period           string        `config:"period"`
+	OffsetBasis      string        `config:"offset_basis"`
+	LagThreshold     int64         `config:"lag_threshold"`
+	Partitions       []int32       `config:"partitions"`
+	SamplePartitions *SampleConfig `config:"sample_partitions"`
+}
+
+type SASLConfig struct {
+	Mechanism    string   `config:"mechanism"`
+	TokenURL     string   `config:"token_url"`
+	ClientID     string   `config:"client_id"`
+	ClientSecret string   `config:"client_secret"`
+	Scopes       []string `config:"scopes"`
 }